@@ -4,9 +4,12 @@ import (
 	"os"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ntm/internal/domain/media"
 	"github.com/ntm/internal/domain/news"
+	"github.com/ntm/internal/domain/section"
 	"github.com/ntm/internal/domain/tag"
 	"github.com/ntm/internal/domain/topic"
+	"github.com/ntm/internal/domain/writer"
 	"github.com/ntm/internal/infrastructure/cache"
 	"github.com/ntm/internal/tools"
 	"gorm.io/gorm"
@@ -46,9 +49,29 @@ func init() {
 	// migrate tables
 	db.AutoMigrate(
 		tag.Tag{},
+		tag.Alias{},
+		writer.Writer{},
+		media.Media{},
 		news.News{},
+		news.Revision{},
+		news.Transition{},
+		news.SlugAlias{},
+		news.Translation{},
 		topic.Topic{},
+		section.Section{},
+		section.Slot{},
 	)
+
+	// create full text search index
+	db.Exec(news.SearchIndex)
+
+	// create tag suggestion and usage indexes
+	for _, v := range tag.Indexes {
+		db.Exec(v)
+	}
+
+	// rows saved before soft delete stored a zero deleted_at
+	db.Exec(news.ClearZeroDeletedAt)
 }
 
 func LaunchApp() {
@@ -56,6 +79,9 @@ func LaunchApp() {
 	registerTagAPIService()
 	registerNewsAPIService()
 	registerTopicAPIService()
+	registerWriterAPIService()
+	registerSectionAPIService()
+	registerMediaAPIService()
 
 	// start server
 	router.Run(os.Getenv("APP_PORT"))
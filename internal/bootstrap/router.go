@@ -2,27 +2,61 @@ package bootstrap
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/ntm/internal/domain/media"
 	"github.com/ntm/internal/domain/news"
+	"github.com/ntm/internal/domain/section"
 	"github.com/ntm/internal/domain/tag"
 	"github.com/ntm/internal/domain/topic"
+	"github.com/ntm/internal/domain/writer"
 )
 
 func registerTagRoute(r *gin.Engine, tagController *tag.HTTPController) {
 	tagRouter := r.Group("/v1/tag")
 	tagRouter.GET("/", tagController.FindAll)
+	tagRouter.GET("/suggest", tagController.Suggest)
+	tagRouter.GET("/stats", tagController.Stats)
+	tagRouter.GET("/cloud", tagController.Cloud)
 	tagRouter.GET("/:id", tagController.FindByID)
 	tagRouter.POST("/", tagController.Add)
+	tagRouter.PUT("/by-name/:name", tagController.FindOrCreate)
+	tagRouter.POST("/:id/merge", tagController.Merge)
+	tagRouter.GET("/:id/aliases", tagController.FindAliases)
+	tagRouter.POST("/:id/aliases", tagController.AddAlias)
+	tagRouter.DELETE("/:id/aliases/:alias", tagController.DeleteAlias)
 	tagRouter.PUT("/:id", tagController.Update)
+	tagRouter.PATCH("/:id", tagController.Patch)
 	tagRouter.DELETE("/:id", tagController.Delete)
 }
 
 func registerNewsRoute(r *gin.Engine, newsController *news.HTTPController) {
 	newsRouter := r.Group("/v1/news")
 	newsRouter.GET("/", newsController.FindAll)
+	newsRouter.GET("/scheduled", newsController.Scheduled)
+	newsRouter.GET("/trash", newsController.Trash)
+	newsRouter.POST("/trash/purge", newsController.PurgeTrash)
+	newsRouter.POST("/bulk", newsController.Bulk)
+	newsRouter.GET("/duplicates", newsController.FindDuplicates)
 	newsRouter.GET("/:id", newsController.FindByID)
+	newsRouter.GET("/slug/:slug", newsController.FindBySlug)
 	newsRouter.POST("/", newsController.Add)
 	newsRouter.PUT("/:id", newsController.Update)
+	newsRouter.PATCH("/:id", newsController.Patch)
 	newsRouter.DELETE("/:id", newsController.Delete)
+	newsRouter.POST("/:id/restore", newsController.Restore)
+	newsRouter.DELETE("/:id/purge", newsController.Purge)
+	newsRouter.POST("/:id/submit", newsController.Submit)
+	newsRouter.POST("/:id/approve", newsController.Approve)
+	newsRouter.POST("/:id/reject", newsController.Reject)
+	newsRouter.POST("/:id/publish", newsController.Publish)
+	newsRouter.POST("/:id/archive", newsController.Archive)
+	newsRouter.GET("/:id/related", newsController.FindRelated)
+	newsRouter.GET("/:id/transitions", newsController.FindTransitions)
+	newsRouter.GET("/:id/revisions", newsController.FindRevisions)
+	newsRouter.GET("/:id/revisions/diff", newsController.DiffRevisions)
+	newsRouter.POST("/:id/revisions/:revision/restore", newsController.RestoreRevision)
+	newsRouter.GET("/:id/translations", newsController.FindTranslations)
+	newsRouter.PUT("/:id/translations/:locale", newsController.SaveTranslation)
+	newsRouter.DELETE("/:id/translations/:locale", newsController.DeleteTranslation)
 }
 
 func registerTopicRoute(r *gin.Engine, topicController *topic.HTTPController) {
@@ -31,5 +65,36 @@ func registerTopicRoute(r *gin.Engine, topicController *topic.HTTPController) {
 	topicRouter.GET("/:id", topicController.FindByID)
 	topicRouter.POST("/", topicController.Add)
 	topicRouter.PUT("/:id", topicController.Update)
+	topicRouter.PATCH("/:id", topicController.Patch)
 	topicRouter.DELETE("/:id", topicController.Delete)
 }
+
+func registerWriterRoute(r *gin.Engine, writerController *writer.HTTPController) {
+	writerRouter := r.Group("/v1/writer")
+	writerRouter.GET("/", writerController.FindAll)
+	writerRouter.GET("/:id", writerController.FindByID)
+	writerRouter.POST("/", writerController.Add)
+	writerRouter.PUT("/:id", writerController.Update)
+	writerRouter.DELETE("/:id", writerController.Delete)
+}
+
+func registerSectionRoute(r *gin.Engine, sectionController *section.HTTPController) {
+	sectionRouter := r.Group("/v1/section")
+	sectionRouter.GET("/", sectionController.FindAll)
+	sectionRouter.GET("/key/:key", sectionController.Render)
+	sectionRouter.GET("/:id", sectionController.FindByID)
+	sectionRouter.POST("/", sectionController.Add)
+	sectionRouter.PUT("/:id", sectionController.Update)
+	sectionRouter.DELETE("/:id", sectionController.Delete)
+	sectionRouter.PUT("/:id/slots", sectionController.SetSlots)
+	sectionRouter.POST("/:id/publish", sectionController.Publish)
+}
+
+func registerMediaRoute(r *gin.Engine, mediaController *media.HTTPController) {
+	mediaRouter := r.Group("/v1/media")
+	mediaRouter.GET("/", mediaController.FindAll)
+	mediaRouter.GET("/:id", mediaController.FindByID)
+	mediaRouter.POST("/", mediaController.Upload)
+	mediaRouter.PUT("/:id", mediaController.Update)
+	mediaRouter.DELETE("/:id", mediaController.Delete)
+}
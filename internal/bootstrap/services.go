@@ -1,27 +1,86 @@
 package bootstrap
 
 import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ntm/internal/domain/media"
 	"github.com/ntm/internal/domain/news"
+	"github.com/ntm/internal/domain/section"
 	"github.com/ntm/internal/domain/tag"
 	"github.com/ntm/internal/domain/topic"
+	"github.com/ntm/internal/domain/writer"
+	"github.com/ntm/internal/infrastructure/storage"
+	"github.com/ntm/internal/pkg/common/http/locale"
+	"github.com/ntm/internal/tools"
 )
 
 func registerTagAPIService() {
 	// Initialize Tag Service
 	tagRepo := tag.NewRepository(db)
 	tagUseCase := tag.NewUseCase(tagRepo)
+	if err := tagUseCase.MigrateKeys(context.Background()); err != nil {
+		log.Println(err.Error())
+	}
 	tagController := tag.NewHTTPController(tagUseCase, cacher)
 	// Build API
 	registerTagRoute(router, tagController)
 }
 
 func registerNewsAPIService() {
+	// Locales, news are served in the reader language then in the fallback ones
+	if lang := locale.Normalize(os.Getenv("NEWS_DEFAULT_LOCALE")); lang != "" {
+		news.DefaultLocale = lang
+	}
+	if fallback := os.Getenv("NEWS_FALLBACK_LOCALES"); fallback != "" {
+		news.FallbackLocales = strings.Split(fallback, ",")
+	}
+	// Near duplicate detection, strict mode refuses to save duplicates instead of warning
+	if threshold, err := strconv.ParseFloat(os.Getenv("NEWS_DUPLICATE_THRESHOLD"), 64); err == nil && threshold > 0 && threshold <= 1 {
+		news.DuplicateThreshold = threshold
+	}
+	if days := tools.StringsToInt(os.Getenv("NEWS_DUPLICATE_WINDOW_DAYS")); days > 0 {
+		news.DuplicateWindow = time.Duration(days) * 24 * time.Hour
+	}
+	news.DuplicateStrict = os.Getenv("NEWS_DUPLICATE_STRICT") == "true"
 	// Initialize Tag Service
 	newsRepo := news.NewRepository(db)
 	newsUseCase := news.NewUseCase(newsRepo)
+	if err := newsUseCase.BackfillSlugs(context.Background()); err != nil {
+		log.Println(err.Error())
+	}
+	if err := newsUseCase.MigrateWriters(context.Background()); err != nil {
+		log.Println(err.Error())
+	}
+	if err := newsUseCase.BackfillContent(context.Background()); err != nil {
+		log.Println(err.Error())
+	}
+	if err := newsUseCase.IndexFingerprints(context.Background()); err != nil {
+		log.Println(err.Error())
+	}
+	if err := newsUseCase.BackfillFingerprints(context.Background()); err != nil {
+		log.Println(err.Error())
+	}
 	newsController := news.NewHTTPController(newsUseCase, cacher)
 	// Build API
 	registerNewsRoute(router, newsController)
+	// Start scheduled news publisher
+	interval := tools.StringsToInt(os.Getenv("NEWS_PUBLISHER_INTERVAL"))
+	if interval <= 0 {
+		interval = 60
+	}
+	publisher := news.NewPublisher(newsUseCase, cacher, time.Duration(interval)*time.Second)
+	go publisher.Run(context.Background())
+	// Start trash purger, disabled when no retention is set
+	retention := tools.StringsToInt(os.Getenv("NEWS_TRASH_RETENTION_DAYS"))
+	if retention > 0 {
+		purger := news.NewPurger(newsUseCase, cacher, time.Duration(retention)*24*time.Hour, time.Hour)
+		go purger.Run(context.Background())
+	}
 }
 
 func registerTopicAPIService() {
@@ -32,3 +91,59 @@ func registerTopicAPIService() {
 	// Build API
 	registerTopicRoute(router, topicController)
 }
+
+func registerWriterAPIService() {
+	// Initialize Writer Service
+	writerRepo := writer.NewRepository(db)
+	writerUseCase := writer.NewUseCase(writerRepo)
+	writerController := writer.NewHTTPController(writerUseCase, cacher)
+	// Build API
+	registerWriterRoute(router, writerController)
+}
+
+func registerSectionAPIService() {
+	// Initialize Section Service
+	sectionRepo := section.NewRepository(db)
+	sectionUseCase := section.NewUseCase(sectionRepo)
+	sectionController := section.NewHTTPController(sectionUseCase, cacher)
+	// Build API
+	registerSectionRoute(router, sectionController)
+}
+
+func registerMediaAPIService() {
+	// Initialize Storage, files are kept on disk unless an S3 compatible bucket is configured
+	var store storage.Storage
+	if os.Getenv("MEDIA_STORAGE") == "s3" {
+		s3Client, err := tools.S3Client(tools.S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			UseSSL:    os.Getenv("S3_USE_SSL") == "true",
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		store = storage.NewStorageS3(s3Client, os.Getenv("S3_BUCKET"), os.Getenv("MEDIA_BASE_URL"))
+	} else {
+		dir := os.Getenv("MEDIA_LOCAL_DIR")
+		if dir == "" {
+			dir = "./uploads"
+		}
+		baseURL := os.Getenv("MEDIA_BASE_URL")
+		if baseURL == "" {
+			baseURL = "/files"
+			router.Static(baseURL, dir)
+		}
+		store = storage.NewStorageLocal(dir, baseURL)
+	}
+	if size := tools.StringsToInt(os.Getenv("MEDIA_MAX_SIZE_MB")); size > 0 {
+		media.MaxSize = int64(size) << 20
+	}
+	// Initialize Media Service
+	mediaRepo := media.NewRepository(db)
+	mediaUseCase := media.NewUseCase(mediaRepo, store)
+	mediaController := media.NewHTTPController(mediaUseCase, cacher)
+	// Build API
+	registerMediaRoute(router, mediaController)
+}
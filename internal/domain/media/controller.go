@@ -0,0 +1,241 @@
+package media
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ntm/internal/infrastructure/cache"
+	"github.com/ntm/internal/pkg/common/http/response"
+	"github.com/ntm/internal/pkg/common/pagination"
+	"github.com/ntm/internal/pkg/common/sorting"
+	"github.com/ntm/internal/tools"
+	"gorm.io/gorm"
+)
+
+// mediaPage is the cached form of a paginated listing.
+type mediaPage struct {
+	Data []Media         `json:"data"`
+	Meta pagination.Meta `json:"meta"`
+}
+
+type HTTPController struct {
+	mediaUseCase UseCase
+	cacher       cache.Cacher
+}
+
+func NewHTTPController(mediaUseCase UseCase, cacher cache.Cacher) *HTTPController {
+	return &HTTPController{
+		mediaUseCase: mediaUseCase,
+		cacher:       cacher,
+	}
+}
+
+func (controller *HTTPController) FindAll(c *gin.Context) {
+	var filter Filter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	if err := filter.Normalize(); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	// validate sort order
+	sort, err := sorting.Parse(filter.Sort, SortFields)
+	if err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid sort parameter", err)
+		return
+	}
+	if len(sort) > 0 && filter.Cursor != "" {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid sort parameter", fmt.Errorf("cursor pagination only supports the default sort order"))
+		return
+	}
+
+	// get from cache
+	cache_key := tools.MD5([]byte(fmt.Sprintf("media:%s:%s", sort, c.Request.URL.Query().Encode())))
+	if controller.cacher.IsExist(cache_key) {
+		log.Println("media | findAll | serve by redis")
+		payload := mediaPage{}
+		if err := json.Unmarshal([]byte(controller.cacher.Get(cache_key).(string)), &payload); err != nil {
+			response.Error(c, http.StatusInternalServerError, err)
+			return
+		}
+		response.SuccessWithMeta(c, http.StatusOK, payload.Data, payload.Meta)
+		return
+	}
+
+	// create context
+	ctx := context.WithValue(context.Background(), ContextKey("media_filter"), filter)
+
+	// get from db
+	media, total, err := controller.mediaUseCase.FindAll(ctx)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// build pagination meta
+	var firstID, lastID uint
+	if len(media) > 0 {
+		firstID, lastID = media[0].ID, media[len(media)-1].ID
+	}
+	page := mediaPage{
+		Data: media,
+		Meta: pagination.NewMeta(c.Request.URL, filter.Pagination, total, len(media), firstID, lastID),
+	}
+
+	// cursors are keyed on id and only valid for the default sort order
+	if len(sort) > 0 {
+		page.Meta.NextCursor, page.Meta.PrevCursor = "", ""
+	}
+
+	// save in cache
+	cache_val, _ := json.Marshal(page)
+	if err := controller.cacher.Put(cache_key, cache_val, 600); err != nil {
+		log.Println(err.Error())
+	}
+
+	response.SuccessWithMeta(c, http.StatusOK, page.Data, page.Meta)
+}
+
+func (controller *HTTPController) FindByID(c *gin.Context) {
+	id := c.Param("id")
+
+	// get from cache
+	cache_key := tools.MD5([]byte(fmt.Sprintf("media_id:" + id)))
+	if controller.cacher.IsExist(cache_key) {
+		log.Println("media | findByID | serve by redis")
+		payload := Media{}
+		if err := json.Unmarshal([]byte(controller.cacher.Get(cache_key).(string)), &payload); err != nil {
+			response.Error(c, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(c, http.StatusOK, payload)
+		return
+	}
+
+	// get from db
+	media, err := controller.mediaUseCase.FindByID(c.Request.Context(), tools.StringsToInt(id))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// save in cache
+	cache_val, _ := json.Marshal(media)
+	if err := controller.cacher.Put(cache_key, cache_val, 600); err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, media)
+}
+
+// Upload stores a multipart file, the caption, credit and alt text are sent as form fields.
+func (controller *HTTPController) Upload(c *gin.Context) {
+	// leave room for the other form fields
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxSize+1<<20)
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		if c.Request.ContentLength > MaxSize {
+			response.Error(c, http.StatusRequestEntityTooLarge, ErrTooLarge)
+			return
+		}
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	var dto MediaDTO
+	if err := c.ShouldBind(&dto); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer f.Close()
+
+	media, err := controller.mediaUseCase.Upload(c.Request.Context(), f, file.Filename, file.Size, dto)
+	if err != nil {
+		if errors.Is(err, ErrTooLarge) {
+			response.Error(c, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		if errors.Is(err, ErrUnsupportedType) {
+			response.Error(c, http.StatusUnsupportedMediaType, err)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, media)
+}
+
+// Update edits the caption, credit and alt text of a media.
+func (controller *HTTPController) Update(c *gin.Context) {
+	var dto MediaDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	media, err := controller.mediaUseCase.Update(c.Request.Context(), tools.StringsToInt(c.Param("id")), dto)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(c, http.StatusNotFound, fmt.Errorf("record not found"))
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, media)
+}
+
+// Delete removes a media and its file, a media attached to a news is answered with 409 Conflict.
+func (controller *HTTPController) Delete(c *gin.Context) {
+	err := controller.mediaUseCase.Delete(c.Request.Context(), tools.StringsToInt(c.Param("id")))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(c, http.StatusNotFound, fmt.Errorf("record not found"))
+			return
+		}
+		if errors.Is(err, ErrAttached) {
+			response.Error(c, http.StatusConflict, err)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, nil)
+}
@@ -0,0 +1,60 @@
+package media
+
+import (
+	"time"
+
+	"github.com/ntm/internal/pkg/common/pagination"
+)
+
+// Media is an uploaded image or file, the file itself lives in the storage under Key.
+type Media struct {
+	ID        uint      `gorm:"primaryKey" json:"id,omitempty"`
+	Key       string    `gorm:"not null;type:varchar(255);uniqueIndex" json:"key,omitempty"`
+	URL       string    `gorm:"not null;type:varchar(500)" json:"url,omitempty"`
+	FileName  string    `gorm:"type:varchar(255)" json:"file_name,omitempty"`
+	MimeType  string    `gorm:"not null;type:varchar(100);index" json:"mime_type,omitempty"`
+	Size      int64     `gorm:"not null" json:"size,omitempty"`
+	Caption   string    `json:"caption,omitempty"`
+	Credit    string    `gorm:"type:varchar(255)" json:"credit,omitempty"`
+	AltText   string    `gorm:"type:varchar(255)" json:"alt_text,omitempty"`
+	CreatedAt time.Time `gorm:"default:current_timestamp;index" json:"created_at,omitempty"`
+	UpdatedAt time.Time `gorm:"default:current_timestamp" json:"updated_at,omitempty"`
+}
+
+// MediaDTO is the description of a media, sent with the upload or on its own to edit it.
+type MediaDTO struct {
+	Caption string `form:"caption" json:"caption"`
+	Credit  string `form:"credit" json:"credit"`
+	AltText string `form:"alt_text" json:"alt_text"`
+}
+
+// DefaultMaxSize is the upload limit when none is configured.
+const DefaultMaxSize = 10 << 20
+
+// MimeTypes lists the accepted types, sniffed from the content, with the extension they are stored with.
+var MimeTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"video/mp4":       ".mp4",
+}
+
+type Filter struct {
+	MimeType     string `form:"mime_type"`
+	CreatedStart string `form:"created_start"`
+	CreatedEnd   string `form:"created_end"`
+	Sort         string `form:"sort"`
+	pagination.Pagination
+}
+
+// SortFields lists the fields a listing can be sorted by.
+var SortFields = map[string]string{
+	"id":         "id",
+	"size":       "size",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+type ContextKey string
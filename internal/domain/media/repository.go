@@ -0,0 +1,103 @@
+package media
+
+import (
+	"context"
+	"time"
+
+	"github.com/ntm/internal/pkg/common/pagination"
+	"github.com/ntm/internal/pkg/common/sorting"
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	GetAll(ctx context.Context) ([]Media, error)
+	Count(ctx context.Context) (int64, error)
+	GetByID(ctx context.Context, id int) (Media, error)
+	GetByIDs(ctx context.Context, ids []uint) ([]Media, error)
+	IsAttached(ctx context.Context, id int) (bool, error)
+	Upsert(ctx context.Context, model Media) (Media, error)
+	DeleteByID(ctx context.Context, id int) error
+	GetDB() *gorm.DB
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db}
+}
+
+func (r *repository) GetAll(ctx context.Context) (res []Media, err error) {
+	filter := ctx.Value(ContextKey("media_filter")).(Filter)
+	exec := r.filter(r.db, filter)
+
+	result := exec.Scopes(sorting.Scope(filter.Sort, SortFields), pagination.Scope(filter.Pagination, "id")).Find(&res)
+	if result.Error != nil {
+		return res, result.Error
+	}
+
+	if filter.IsBackward() {
+		for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+			res[i], res[j] = res[j], res[i]
+		}
+	}
+
+	return res, nil
+}
+
+func (r *repository) Count(ctx context.Context) (total int64, err error) {
+	filter := ctx.Value(ContextKey("media_filter")).(Filter)
+	result := r.filter(r.db.Model(&Media{}), filter).Count(&total)
+	return total, result.Error
+}
+
+// filter applies the listing conditions shared by GetAll and Count.
+func (r *repository) filter(exec *gorm.DB, filter Filter) *gorm.DB {
+	var dateValid bool
+	_, a := time.Parse("2006-01-02", filter.CreatedStart)
+	_, b := time.Parse("2006-01-02", filter.CreatedEnd)
+	if a == nil && b == nil {
+		dateValid = true
+	}
+
+	if filter.MimeType != "" {
+		exec = exec.Where("mime_type = ?", filter.MimeType)
+	}
+
+	if dateValid {
+		exec = exec.Where("to_char(created_at, 'YYYY-MM-DD') between ? and ?", filter.CreatedStart, filter.CreatedEnd)
+	}
+
+	return exec
+}
+
+func (r *repository) GetByID(ctx context.Context, id int) (res Media, err error) {
+	result := r.db.First(&res, id)
+	return res, result.Error
+}
+
+func (r *repository) GetByIDs(ctx context.Context, ids []uint) (res []Media, err error) {
+	result := r.db.Where("id in ?", ids).Find(&res)
+	return res, result.Error
+}
+
+// IsAttached reports whether a news, trashed or not, still uses the media.
+func (r *repository) IsAttached(ctx context.Context, id int) (attached bool, err error) {
+	result := r.db.Raw("SELECT EXISTS (SELECT 1 FROM news_media WHERE media_id = ?) OR EXISTS (SELECT 1 FROM news WHERE cover_id = ?)", id, id).Scan(&attached)
+	return attached, result.Error
+}
+
+func (r *repository) Upsert(ctx context.Context, model Media) (res Media, err error) {
+	result := r.db.Save(&model)
+	return model, result.Error
+}
+
+func (r *repository) DeleteByID(ctx context.Context, id int) error {
+	result := r.db.Delete(&Media{ID: uint(id)})
+	return result.Error
+}
+
+func (r *repository) GetDB() *gorm.DB {
+	return r.db
+}
@@ -0,0 +1,161 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ntm/internal/infrastructure/storage"
+)
+
+var (
+	ErrTooLarge        = errors.New("file is too large")
+	ErrUnsupportedType = errors.New("file type is not supported")
+	ErrAttached        = errors.New("media is attached to a news")
+)
+
+// MaxSize is the largest file accepted, in bytes.
+var MaxSize int64 = DefaultMaxSize
+
+type UseCase interface {
+	FindAll(context context.Context) ([]Media, int64, error)
+	FindByID(context context.Context, id int) (Media, error)
+	Upload(context context.Context, file io.Reader, fileName string, size int64, dto MediaDTO) (Media, error)
+	Update(context context.Context, id int, dto MediaDTO) (Media, error)
+	Delete(context context.Context, id int) error
+}
+
+type useCase struct {
+	repo  Repository
+	store storage.Storage
+}
+
+func NewUseCase(repo Repository, store storage.Storage) UseCase {
+	return &useCase{
+		repo:  repo,
+		store: store,
+	}
+}
+
+func (us *useCase) FindAll(context context.Context) (res []Media, total int64, err error) {
+	res, err = us.repo.GetAll(context)
+	if err != nil {
+		return res, total, err
+	}
+
+	total, err = us.repo.Count(context)
+	return res, total, err
+}
+
+func (us *useCase) FindByID(context context.Context, id int) (res Media, err error) {
+	res, err = us.repo.GetByID(context, id)
+	return res, err
+}
+
+// Upload stores a file and records it, the type is sniffed from the content
+// and never taken from the file name or the client.
+func (us *useCase) Upload(context context.Context, file io.Reader, fileName string, size int64, dto MediaDTO) (res Media, err error) {
+	if size > MaxSize {
+		return res, fmt.Errorf("%w, the limit is %d bytes", ErrTooLarge, MaxSize)
+	}
+	if size <= 0 {
+		return res, fmt.Errorf("invalid parameters")
+	}
+
+	// sniff the type from the first bytes
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return res, err
+	}
+	head = head[:n]
+
+	mimeType := strings.TrimSpace(strings.Split(http.DetectContentType(head), ";")[0])
+	ext, ok := MimeTypes[mimeType]
+	if !ok {
+		return res, fmt.Errorf("%w: %s", ErrUnsupportedType, mimeType)
+	}
+
+	key, err := newKey(ext)
+	if err != nil {
+		return res, err
+	}
+
+	body := io.MultiReader(bytes.NewReader(head), io.LimitReader(file, size-int64(n)))
+	if err = us.store.Put(context, key, body, size, mimeType); err != nil {
+		return res, err
+	}
+
+	res, err = us.repo.Upsert(context, Media{
+		Key:      key,
+		URL:      us.store.URL(key),
+		FileName: filepath.Base(fileName),
+		MimeType: mimeType,
+		Size:     size,
+		Caption:  strings.TrimSpace(dto.Caption),
+		Credit:   strings.TrimSpace(dto.Credit),
+		AltText:  strings.TrimSpace(dto.AltText),
+	})
+	if err != nil {
+		// do not keep a file nothing points at
+		if errDelete := us.store.Delete(context, key); errDelete != nil {
+			log.Println(errDelete.Error())
+		}
+		return res, err
+	}
+
+	return res, nil
+}
+
+func (us *useCase) Update(context context.Context, id int, dto MediaDTO) (res Media, err error) {
+	res, err = us.repo.GetByID(context, id)
+	if err != nil {
+		return res, err
+	}
+
+	res.Caption = strings.TrimSpace(dto.Caption)
+	res.Credit = strings.TrimSpace(dto.Credit)
+	res.AltText = strings.TrimSpace(dto.AltText)
+	res, err = us.repo.Upsert(context, res)
+	return res, err
+}
+
+// Delete removes a media and its file, media still attached to a news are kept.
+func (us *useCase) Delete(context context.Context, id int) (err error) {
+	media, err := us.repo.GetByID(context, id)
+	if err != nil {
+		return err
+	}
+
+	attached, err := us.repo.IsAttached(context, id)
+	if err != nil {
+		return err
+	}
+	if attached {
+		return ErrAttached
+	}
+
+	if err = us.repo.DeleteByID(context, id); err != nil {
+		return err
+	}
+
+	return us.store.Delete(context, media.Key)
+}
+
+// newKey returns a unique storage key grouped by upload month.
+func newKey(ext string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("media/%s/%s%s", time.Now().Format("2006/01"), hex.EncodeToString(b), ext), nil
+}
@@ -3,16 +3,32 @@ package news
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ntm/internal/infrastructure/cache"
+	"github.com/ntm/internal/pkg/common/http/etag"
+	"github.com/ntm/internal/pkg/common/http/locale"
 	"github.com/ntm/internal/pkg/common/http/response"
+	"github.com/ntm/internal/pkg/common/pagination"
+	"github.com/ntm/internal/pkg/common/patch"
+	"github.com/ntm/internal/pkg/common/sorting"
 	"github.com/ntm/internal/tools"
+	"gorm.io/gorm"
 )
 
+// newsPage is the cached form of a paginated listing.
+type newsPage struct {
+	Data []News          `json:"data"`
+	Meta pagination.Meta `json:"meta"`
+}
+
 type HTTPController struct {
 	newsUseCase UseCase
 	cacher      cache.Cacher
@@ -32,43 +48,122 @@ func (controller *HTTPController) FindAll(c *gin.Context) {
 		return
 	}
 
+	controller.findAll(c, filter, "news")
+}
+
+// Scheduled lists the news waiting for the publisher, soonest first.
+func (controller *HTTPController) Scheduled(c *gin.Context) {
+	var filter Filter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	filter.Status = string(StatusScheduled)
+	if filter.Sort == "" {
+		filter.Sort = "publish_at"
+	}
+
+	controller.findAll(c, filter, "news_scheduled")
+}
+
+// Trash lists the deleted news, most recently deleted first.
+func (controller *HTTPController) Trash(c *gin.Context) {
+	var filter Filter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	filter.Trashed = true
+	if filter.Sort == "" {
+		filter.Sort = "-deleted_at"
+	}
+
+	controller.findAll(c, filter, "news_trash")
+}
+
+// findAll serves a paginated listing, prefix separates the cache keys of each listing.
+func (controller *HTTPController) findAll(c *gin.Context, filter Filter, prefix string) {
+	if err := filter.Normalize(); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	if err := filter.ParseTags(); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	// validate sort order
+	sort, err := sorting.Parse(filter.Sort, SortFields)
+	if err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid sort parameter", err)
+		return
+	}
+	if (len(sort) > 0 || filter.Q != "") && filter.Cursor != "" {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid sort parameter", fmt.Errorf("cursor pagination only supports the default sort order"))
+		return
+	}
+
 	// get from cache
-	cache_key := tools.MD5([]byte(fmt.Sprintf("news:%s", c.Request.URL.Query().Encode())))
+	ctx := localeContext(c, context.Background())
+	locales := strings.Join(ctx.Value(ContextKey("locales")).([]string), ",")
+	c.Header("Vary", "Accept-Language")
+
+	cache_key := tools.MD5([]byte(fmt.Sprintf("%s:%s:%s:%s", prefix, sort, locales, c.Request.URL.Query().Encode())))
 	if controller.cacher.IsExist(cache_key) {
 		log.Println("news | findAll | serve by redis")
-		payload := []News{}
+		payload := newsPage{}
 		if err := json.Unmarshal([]byte(controller.cacher.Get(cache_key).(string)), &payload); err != nil {
 			response.Error(c, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(c, http.StatusOK, payload)
+		response.SuccessWithMeta(c, http.StatusOK, payload.Data, payload.Meta)
 		return
 	}
 
 	// create context
-	ctx := context.WithValue(context.Background(), ContextKey("news_filter"), filter)
+	ctx = context.WithValue(ctx, ContextKey("news_filter"), filter)
 
 	// get from db
-	news, err := controller.newsUseCase.FindAll(ctx)
+	news, total, err := controller.newsUseCase.FindAll(ctx)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, err)
 		return
 	}
 
+	// build pagination meta
+	var firstID, lastID uint
+	if len(news) > 0 {
+		firstID, lastID = news[0].ID, news[len(news)-1].ID
+	}
+	page := newsPage{
+		Data: news,
+		Meta: pagination.NewMeta(c.Request.URL, filter.Pagination, total, len(news), firstID, lastID),
+	}
+
+	// cursors are keyed on id and only valid for the default sort order
+	if len(sort) > 0 || filter.Q != "" {
+		page.Meta.NextCursor, page.Meta.PrevCursor = "", ""
+	}
+
 	// save in cache
-	cache_val, _ := json.Marshal(news)
+	cache_val, _ := json.Marshal(page)
 	if err := controller.cacher.Put(cache_key, cache_val, 60); err != nil {
 		log.Println(err.Error())
 	}
 
-	response.Success(c, http.StatusOK, news)
+	response.SuccessWithMeta(c, http.StatusOK, page.Data, page.Meta)
 }
 
 func (controller *HTTPController) FindByID(c *gin.Context) {
 	id := c.Param("id")
 
 	// get from cache
-	cache_key := tools.MD5([]byte(fmt.Sprintf("news_id:" + id)))
+	ctx := localeContext(c, c.Request.Context())
+	locales := strings.Join(ctx.Value(ContextKey("locales")).([]string), ",")
+	cache_key := tools.MD5([]byte(fmt.Sprintf("news_id:%s:%s", id, locales)))
 	if controller.cacher.IsExist(cache_key) {
 		log.Println("news | findByID | serve by redis")
 		payload := News{}
@@ -76,12 +171,14 @@ func (controller *HTTPController) FindByID(c *gin.Context) {
 			response.Error(c, http.StatusInternalServerError, err)
 			return
 		}
+		c.Header("ETag", etag.Format(payload.Version))
+		localeHeaders(c, payload.Locale)
 		response.Success(c, http.StatusOK, payload)
 		return
 	}
 
 	// get from db
-	news, err := controller.newsUseCase.FindByID(c.Request.Context(), tools.StringsToInt(id))
+	news, err := controller.newsUseCase.FindByID(ctx, tools.StringsToInt(id))
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, err)
 		return
@@ -93,6 +190,57 @@ func (controller *HTTPController) FindByID(c *gin.Context) {
 		log.Println(err.Error())
 	}
 
+	c.Header("ETag", etag.Format(news.Version))
+	localeHeaders(c, news.Locale)
+	response.Success(c, http.StatusOK, news)
+}
+
+// FindBySlug serves a news by its slug, old slugs are redirected to the current one.
+func (controller *HTTPController) FindBySlug(c *gin.Context) {
+	slug := c.Param("slug")
+
+	// get from cache
+	ctx := localeContext(c, c.Request.Context())
+	locales := strings.Join(ctx.Value(ContextKey("locales")).([]string), ",")
+	cache_key := tools.MD5([]byte(fmt.Sprintf("news_slug:%s:%s", slug, locales)))
+	if controller.cacher.IsExist(cache_key) {
+		log.Println("news | findBySlug | serve by redis")
+		payload := News{}
+		if err := json.Unmarshal([]byte(controller.cacher.Get(cache_key).(string)), &payload); err != nil {
+			response.Error(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.Header("ETag", etag.Format(payload.Version))
+		localeHeaders(c, payload.Locale)
+		response.Success(c, http.StatusOK, payload)
+		return
+	}
+
+	// get from db
+	news, canonical, err := controller.newsUseCase.FindBySlug(ctx, slug)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(c, http.StatusNotFound, fmt.Errorf("record not found"))
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// redirect old slug
+	if canonical != slug {
+		c.Redirect(http.StatusMovedPermanently, strings.TrimSuffix(c.Request.URL.Path, slug)+canonical)
+		return
+	}
+
+	// save in cache
+	cache_val, _ := json.Marshal(news)
+	if err := controller.cacher.Put(cache_key, cache_val, 60); err != nil {
+		log.Println(err.Error())
+	}
+
+	c.Header("ETag", etag.Format(news.Version))
+	localeHeaders(c, news.Locale)
 	response.Success(c, http.StatusOK, news)
 }
 
@@ -106,8 +254,21 @@ func (controller *HTTPController) Add(c *gin.Context) {
 		return
 	}
 
-	_, err = controller.newsUseCase.Save(c.Request.Context(), dto)
+	news, err := controller.newsUseCase.Save(editorContext(c), dto)
 	if err != nil {
+		var duplicate *DuplicateError
+		if errors.As(err, &duplicate) {
+			response.ErrorWithData(c, http.StatusConflict, err, duplicate)
+			return
+		}
+		if errors.Is(err, ErrInvalidTransition) {
+			response.Error(c, http.StatusConflict, err)
+			return
+		}
+		if errors.Is(err, ErrReasonRequired) || errors.Is(err, ErrCreateStatus) {
+			response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+			return
+		}
 		response.Error(c, http.StatusInternalServerError, err)
 		return
 	}
@@ -118,13 +279,18 @@ func (controller *HTTPController) Add(c *gin.Context) {
 		log.Println(err.Error())
 	}
 
-	response.Success(c, http.StatusOK, nil)
+	response.SuccessWithMessage(c, http.StatusOK, warnDuplicates(c, news), nil)
 }
 
 func (controller *HTTPController) Update(c *gin.Context) {
 	var err error
 	var dto NewsDTO
 
+	version, ok := etag.IfMatch(c)
+	if !ok {
+		return
+	}
+
 	err = c.Bind(&dto)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, err)
@@ -140,8 +306,26 @@ func (controller *HTTPController) Update(c *gin.Context) {
 
 	// update tag
 	dto.ID = news.ID
-	_, err = controller.newsUseCase.Save(c.Request.Context(), dto)
+	dto.Version = version
+	news, err = controller.newsUseCase.Save(editorContext(c), dto)
 	if err != nil {
+		var duplicate *DuplicateError
+		if errors.As(err, &duplicate) {
+			response.ErrorWithData(c, http.StatusConflict, err, duplicate)
+			return
+		}
+		if errors.Is(err, etag.ErrPreconditionFailed) {
+			controller.preconditionFailed(c, int(dto.ID))
+			return
+		}
+		if errors.Is(err, ErrInvalidTransition) {
+			response.Error(c, http.StatusConflict, err)
+			return
+		}
+		if errors.Is(err, ErrReasonRequired) {
+			response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+			return
+		}
 		response.Error(c, http.StatusInternalServerError, err)
 		return
 	}
@@ -152,14 +336,382 @@ func (controller *HTTPController) Update(c *gin.Context) {
 		log.Println(err.Error())
 	}
 
-	response.Success(c, http.StatusOK, nil)
+	c.Header("ETag", etag.Format(news.Version))
+	response.SuccessWithMessage(c, http.StatusOK, warnDuplicates(c, news), nil)
+}
+
+// Patch accepts a JSON Merge Patch (application/merge-patch+json)
+// or a JSON Patch (application/json-patch+json) and returns the updated news.
+func (controller *HTTPController) Patch(c *gin.Context) {
+	version, ok := etag.IfMatch(c)
+	if !ok {
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	id := tools.StringsToInt(c.Param("id"))
+	news, err := controller.newsUseCase.Patch(editorContext(c), id, version, c.ContentType(), body)
+	if err != nil {
+		var duplicate *DuplicateError
+		switch {
+		case errors.As(err, &duplicate):
+			response.ErrorWithData(c, http.StatusConflict, err, duplicate)
+		case errors.Is(err, etag.ErrPreconditionFailed):
+			controller.preconditionFailed(c, id)
+		case errors.Is(err, patch.ErrUnsupportedMediaType):
+			response.Error(c, http.StatusUnsupportedMediaType, err)
+		case errors.Is(err, patch.ErrInvalidPatch):
+			response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			response.Error(c, http.StatusNotFound, fmt.Errorf("record not found"))
+		case errors.Is(err, ErrInvalidTransition):
+			response.Error(c, http.StatusConflict, err)
+		case errors.Is(err, ErrReasonRequired):
+			response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		default:
+			response.Error(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	c.Header("ETag", etag.Format(news.Version))
+	response.SuccessWithMessage(c, http.StatusOK, warnDuplicates(c, news), news)
 }
 
 func (controller *HTTPController) Delete(c *gin.Context) {
 	var err error
 
-	err = controller.newsUseCase.Delete(c.Request.Context(), tools.StringsToInt(c.Param("id")))
+	version, ok := etag.IfMatch(c)
+	if !ok {
+		return
+	}
+
+	id := tools.StringsToInt(c.Param("id"))
+	err = controller.newsUseCase.Delete(editorContext(c), id, version)
+	if err != nil {
+		if errors.Is(err, etag.ErrPreconditionFailed) {
+			controller.preconditionFailed(c, id)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, nil)
+}
+
+func (controller *HTTPController) FindRevisions(c *gin.Context) {
+	revisions, err := controller.newsUseCase.FindRevisions(c.Request.Context(), tools.StringsToInt(c.Param("id")))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.Success(c, http.StatusOK, revisions)
+}
+
+func (controller *HTTPController) DiffRevisions(c *gin.Context) {
+	from, to := tools.StringsToInt(c.Query("from")), tools.StringsToInt(c.Query("to"))
+	if from == 0 || to == 0 {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", fmt.Errorf("from and to revision are required"))
+		return
+	}
+
+	diff, err := controller.newsUseCase.DiffRevisions(c.Request.Context(), tools.StringsToInt(c.Param("id")), from, to)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.Success(c, http.StatusOK, diff)
+}
+
+func (controller *HTTPController) RestoreRevision(c *gin.Context) {
+	news, err := controller.newsUseCase.RestoreRevision(editorContext(c), tools.StringsToInt(c.Param("id")), tools.StringsToInt(c.Param("revision")))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, news)
+}
+
+func (controller *HTTPController) Restore(c *gin.Context) {
+	news, err := controller.newsUseCase.Restore(editorContext(c), tools.StringsToInt(c.Param("id")))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, news)
+}
+
+func (controller *HTTPController) Purge(c *gin.Context) {
+	err := controller.newsUseCase.Purge(c.Request.Context(), tools.StringsToInt(c.Param("id")))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, nil)
+}
+
+// PurgeTrash empties the trash, older_than_days keeps the news deleted more recently.
+func (controller *HTTPController) PurgeTrash(c *gin.Context) {
+	days := tools.StringsToInt(c.Query("older_than_days"))
+	if days < 0 {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", fmt.Errorf("older_than_days must not be negative"))
+		return
+	}
+
+	purged, err := controller.newsUseCase.PurgeTrash(c.Request.Context(), time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"purged": purged})
+}
+
+func (controller *HTTPController) Submit(c *gin.Context) {
+	controller.transition(c, StatusInReview)
+}
+
+func (controller *HTTPController) Approve(c *gin.Context) {
+	controller.transition(c, StatusApproved)
+}
+
+func (controller *HTTPController) Reject(c *gin.Context) {
+	controller.transition(c, StatusDraft)
+}
+
+// Publish publishes an approved news right away, or schedules it when a future publish_at is given.
+func (controller *HTTPController) Publish(c *gin.Context) {
+	controller.transition(c, StatusPublish)
+}
+
+func (controller *HTTPController) Archive(c *gin.Context) {
+	controller.transition(c, StatusArchived)
+}
+
+// FindRelated lists the published news to read next, ?limit defaults to 5.
+func (controller *HTTPController) FindRelated(c *gin.Context) {
+	id := c.Param("id")
+	limit := tools.StringsToInt(c.DefaultQuery("limit", tools.IntToString(DefaultRelatedLimit)))
+	if limit < 1 || limit > MaxRelatedLimit {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", fmt.Errorf("limit must be between 1 and %d", MaxRelatedLimit))
+		return
+	}
+
+	// get from cache, saving any news flushes it so tag or topic changes are picked up
+	cache_key := tools.MD5([]byte(fmt.Sprintf("news_related:%s:%d", id, limit)))
+	if controller.cacher.IsExist(cache_key) {
+		log.Println("news | findRelated | serve by redis")
+		payload := []News{}
+		if err := json.Unmarshal([]byte(controller.cacher.Get(cache_key).(string)), &payload); err != nil {
+			response.Error(c, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(c, http.StatusOK, payload)
+		return
+	}
+
+	// get from db
+	news, err := controller.newsUseCase.FindRelated(c.Request.Context(), tools.StringsToInt(id), limit)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(c, http.StatusNotFound, fmt.Errorf("record not found"))
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// save in cache
+	cache_val, _ := json.Marshal(news)
+	if err := controller.cacher.Put(cache_key, cache_val, 600); err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, news)
+}
+
+func (controller *HTTPController) FindTransitions(c *gin.Context) {
+	transitions, err := controller.newsUseCase.FindTransitions(c.Request.Context(), tools.StringsToInt(c.Param("id")))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.Success(c, http.StatusOK, transitions)
+}
+
+func (controller *HTTPController) transition(c *gin.Context, to Status) {
+	var dto TransitionDTO
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&dto); err != nil {
+			response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+			return
+		}
+	}
+
+	// every transition must say who made it
+	if c.GetHeader("X-Editor") == "" {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", fmt.Errorf("X-Editor header is required"))
+		return
+	}
+
+	if to == StatusPublish && dto.PublishAt != nil && dto.PublishAt.After(time.Now()) {
+		to = StatusScheduled
+	}
+
+	news, err := controller.newsUseCase.Transition(editorContext(c), tools.StringsToInt(c.Param("id")), to, dto.Reason, dto.PublishAt)
 	if err != nil {
+		if errors.Is(err, ErrInvalidTransition) {
+			response.Error(c, http.StatusConflict, err)
+			return
+		}
+		if errors.Is(err, ErrReasonRequired) {
+			response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, news)
+}
+
+// Bulk runs a list of operations and answers with a result per operation,
+// the cache is flushed once for the whole batch.
+func (controller *HTTPController) Bulk(c *gin.Context) {
+	var dto BulkDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	if err := dto.Validate(); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	// every change must say who made it
+	if c.GetHeader("X-Editor") == "" {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", fmt.Errorf("X-Editor header is required"))
+		return
+	}
+
+	results, err := controller.newsUseCase.Bulk(editorContext(c), dto)
+	if err != nil {
+		if errors.Is(err, ErrBulkRolledBack) {
+			response.ErrorWithData(c, http.StatusUnprocessableEntity, err, results)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// flush cache, only when something changed
+	for _, v := range results {
+		if v.Success {
+			if err := controller.cacher.Flush(); err != nil {
+				log.Println(err.Error())
+			}
+			break
+		}
+	}
+
+	response.Success(c, http.StatusOK, results)
+}
+
+func (controller *HTTPController) FindTranslations(c *gin.Context) {
+	translations, err := controller.newsUseCase.FindTranslations(c.Request.Context(), tools.StringsToInt(c.Param("id")))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.Success(c, http.StatusOK, translations)
+}
+
+// SaveTranslation adds the translation of a news in a locale or replaces the existing one.
+func (controller *HTTPController) SaveTranslation(c *gin.Context) {
+	var dto TranslationDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	translation, err := controller.newsUseCase.SaveTranslation(c.Request.Context(), tools.StringsToInt(c.Param("id")), c.Param("locale"), dto)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, translation)
+}
+
+func (controller *HTTPController) DeleteTranslation(c *gin.Context) {
+	err := controller.newsUseCase.DeleteTranslation(c.Request.Context(), tools.StringsToInt(c.Param("id")), c.Param("locale"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(c, http.StatusNotFound, fmt.Errorf("record not found"))
+			return
+		}
 		response.Error(c, http.StatusInternalServerError, err)
 		return
 	}
@@ -172,3 +724,90 @@ func (controller *HTTPController) Delete(c *gin.Context) {
 
 	response.Success(c, http.StatusOK, nil)
 }
+
+// localeContext carries the locales to serve news in, taken from the lang
+// query parameter and the Accept-Language header followed by the fallback locales.
+func localeContext(c *gin.Context, ctx context.Context) context.Context {
+	return context.WithValue(ctx, ContextKey("locales"), locale.Chain(locale.Preferred(c), FallbackLocales))
+}
+
+// localeHeaders reports the locale a news is served in.
+func localeHeaders(c *gin.Context, lang string) {
+	c.Header("Content-Language", lang)
+	c.Header("Vary", "Accept-Language")
+}
+
+// FindDuplicates lists the groups of news with similar contents,
+// threshold is the lowest similarity between 0 and 1, it defaults to the one used when saving.
+func (controller *HTTPController) FindDuplicates(c *gin.Context) {
+	threshold := DuplicateThreshold
+	if v := c.Query("threshold"); v != "" {
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil || parsed <= 0 || parsed > 1 {
+			response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", fmt.Errorf("threshold must be between 0 and 1"))
+			return
+		}
+		threshold = parsed
+	}
+
+	// get from cache, saving any news flushes it
+	cache_key := tools.MD5([]byte(fmt.Sprintf("news_duplicates:%v", threshold)))
+	if controller.cacher.IsExist(cache_key) {
+		log.Println("news | findDuplicates | serve by redis")
+		payload := []DuplicateCluster{}
+		if err := json.Unmarshal([]byte(controller.cacher.Get(cache_key).(string)), &payload); err != nil {
+			response.Error(c, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(c, http.StatusOK, payload)
+		return
+	}
+
+	// get from db
+	clusters, err := controller.newsUseCase.FindDuplicates(c.Request.Context(), threshold)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// save in cache
+	cache_val, _ := json.Marshal(clusters)
+	if err := controller.cacher.Put(cache_key, cache_val, 600); err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, clusters)
+}
+
+// warnDuplicates reports a saved news similar to other news in the Warning header and the message.
+func warnDuplicates(c *gin.Context, news News) string {
+	if len(news.Duplicates) == 0 {
+		return "success"
+	}
+
+	msg := (&DuplicateError{IDs: news.Duplicates}).Error()
+	c.Header("Warning", fmt.Sprintf("299 - %q", msg))
+	return msg
+}
+
+// editorContext carries the user making the change, taken from the X-Editor header.
+func editorContext(c *gin.Context) context.Context {
+	return context.WithValue(c.Request.Context(), ContextKey("editor"), c.GetHeader("X-Editor"))
+}
+
+// preconditionFailed answers a write made against a stale version
+// with the current representation of the news.
+func (controller *HTTPController) preconditionFailed(c *gin.Context, id int) {
+	news, err := controller.newsUseCase.FindByID(c.Request.Context(), id)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+	if news.ID == 0 {
+		response.Error(c, http.StatusNotFound, fmt.Errorf("record not found"))
+		return
+	}
+
+	c.Header("ETag", etag.Format(news.Version))
+	response.ErrorWithData(c, http.StatusPreconditionFailed, etag.ErrPreconditionFailed, news)
+}
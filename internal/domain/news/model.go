@@ -1,50 +1,499 @@
 package news
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/ntm/internal/domain/media"
 	"github.com/ntm/internal/domain/tag"
 	"github.com/ntm/internal/domain/topic"
+	"github.com/ntm/internal/domain/writer"
+	"github.com/ntm/internal/pkg/common/pagination"
+	"github.com/ntm/internal/tools"
+	"gorm.io/gorm"
 )
 
 type News struct {
-	ID        uint      `gorm:"primaryKey"`
-	Title     string    `gorm:"not null"`
-	Writer    string    `gorm:"not null;type:varchar(100)"`
-	Content   string    `gorm:"not null"`
-	Status    string    `gorm:"not null;type:varchar(20)"`
-	Tags      []tag.Tag `gorm:"many2many:news_tags;"`
-	TopicID   uint
-	Topic     topic.Topic
-	PublishAt time.Time `gorm:"default:current_timestamp;"`
-	CreatedAt time.Time `gorm:"default:current_timestamp;index"`
-	UpdatedAt time.Time `gorm:"default:current_timestamp"`
-	DeletedAt time.Time `gorm:"default:null"`
+	ID            uint          `gorm:"primaryKey"`
+	Title         string        `gorm:"not null"`
+	Slug          string        `gorm:"type:varchar(255);default:null;index:idx_news_slug,unique,where:slug <> ''"`
+	Writer        string        `gorm:"not null;type:varchar(100)"`
+	WriterID      uint          `gorm:"default:null;index"`
+	WriterProfile writer.Writer `gorm:"foreignKey:WriterID"`
+	Content       string        `gorm:"not null"`
+	ContentFormat string        `gorm:"type:varchar(10);default:null"`
+	ContentHTML   string        `gorm:"default:null"`
+	Locale        string        `gorm:"type:varchar(10);default:null"`
+	Status        string        `gorm:"not null;type:varchar(20)"`
+	Tags          []tag.Tag     `gorm:"many2many:news_tags;"`
+	TopicID       uint
+	Topic         topic.Topic
+	CoverID       *uint          `gorm:"default:null;index"`
+	Cover         *media.Media   `gorm:"foreignKey:CoverID"`
+	Media         []media.Media  `gorm:"many2many:news_media;"`
+	PublishAt     time.Time      `gorm:"default:current_timestamp;"`
+	UnpublishAt   *time.Time     `gorm:"default:null;index"`
+	CreatedAt     time.Time      `gorm:"default:current_timestamp;index"`
+	UpdatedAt     time.Time      `gorm:"default:current_timestamp"`
+	DeletedAt     gorm.DeletedAt `gorm:"default:null;index"`
+	Version       uint           `gorm:"not null;default:1"`
+	Fingerprint   int64          `gorm:"default:null;index" json:"-"`
+
+	// search result fields, only filled when searching with a keyword
+	SearchRank   float64 `gorm:"->;-:migration" json:",omitempty"`
+	TitleSnippet string  `gorm:"->;-:migration" json:",omitempty"`
+	Snippet      string  `gorm:"->;-:migration" json:",omitempty"`
+
+	// related articles score, only filled when listing related news
+	RelatedScore float64 `gorm:"->;-:migration" json:",omitempty"`
+
+	// ids of the recent news with a similar content, only filled when saving
+	Duplicates []uint `gorm:"-" json:",omitempty"`
+
+	// derived from the content when saved
+	Excerpt     string `gorm:"default:null"`
+	WordCount   int    `gorm:"not null;default:0"`
+	ReadingTime int    `gorm:"not null;default:0"`
+}
+
+const excerptLength = 200
+
+// AfterFind defaults the locale, news saved before locales existed are in the default locale.
+func (n *News) AfterFind(tx *gorm.DB) error {
+	if n.Locale == "" {
+		n.Locale = DefaultLocale
+	}
+	return nil
+}
+
+// summarize derives the excerpt, word count and reading time of a rendered content.
+func summarize(contentHTML string) (excerpt string, words int, minutes int) {
+	text := tools.PlainText(contentHTML)
+	words = len(strings.Fields(text))
+	return tools.Excerpt(text, excerptLength), words, tools.ReadingTime(words)
+}
+
+// translate replaces the title and content of a news with one of its translations.
+func (n *News) translate(t Translation) {
+	n.Title = t.Title
+	n.Content = t.Content
+	n.ContentFormat = t.ContentFormat
+	n.ContentHTML = t.ContentHTML
+	n.Locale = t.Locale
+	n.Excerpt = t.Excerpt
+	n.WordCount = t.WordCount
+	n.ReadingTime = t.ReadingTime
+}
+
+// DefaultLocale is the language of news saved without one, FallbackLocales
+// are tried after the languages asked by the reader.
+var (
+	DefaultLocale   = "id"
+	FallbackLocales []string
+)
+
+// near duplicate detection, news created within DuplicateWindow with a content
+// at least DuplicateThreshold similar are reported, strict mode refuses to save them.
+var (
+	DuplicateThreshold = 0.85
+	DuplicateWindow    = 30 * 24 * time.Hour
+	DuplicateStrict    = false
+)
+
+// minDuplicateBands cuts fingerprints in bands of at most 16 bits when indexing them.
+const minDuplicateBands = 4
+
+// duplicateBands is the number of bands fingerprints are cut into for a threshold,
+// similar fingerprints differ in at most maxBits bits so with one band more they share a band.
+func duplicateBands(threshold float64) int {
+	maxBits := int(64*(1-threshold) + 1e-9)
+	if maxBits+1 < minDuplicateBands {
+		return minDuplicateBands
+	}
+	return maxBits + 1
+}
+
+var ErrDuplicate = errors.New("content is similar to other news")
+
+// DuplicateError names the news a content is similar to.
+type DuplicateError struct {
+	IDs []uint `json:"duplicates"`
+}
+
+func (e *DuplicateError) Error() string {
+	ids := make([]string, 0, len(e.IDs))
+	for _, v := range e.IDs {
+		ids = append(ids, fmt.Sprint(v))
+	}
+	return fmt.Sprintf("%s: %s", ErrDuplicate, strings.Join(ids, ", "))
+}
+
+func (e *DuplicateError) Unwrap() error {
+	return ErrDuplicate
+}
+
+// DuplicateCluster is a group of news with similar contents, Similarity is
+// the lowest similarity between two news of the group.
+type DuplicateCluster struct {
+	IDs        []uint  `json:"ids"`
+	Similarity float64 `json:"similarity"`
+}
+
+// Translation is the title and content of a news in another language.
+type Translation struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	NewsID        uint      `gorm:"not null;uniqueIndex:idx_translation_news_locale" json:"news_id"`
+	Locale        string    `gorm:"not null;type:varchar(10);uniqueIndex:idx_translation_news_locale" json:"locale"`
+	Title         string    `gorm:"not null" json:"title"`
+	Content       string    `gorm:"not null" json:"content"`
+	ContentFormat string    `gorm:"type:varchar(10);default:null" json:"content_format"`
+	ContentHTML   string    `gorm:"default:null" json:"content_html"`
+	Excerpt       string    `gorm:"default:null" json:"excerpt"`
+	WordCount     int       `gorm:"not null;default:0" json:"word_count"`
+	ReadingTime   int       `gorm:"not null;default:0" json:"reading_time"`
+	CreatedAt     time.Time `gorm:"default:current_timestamp" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"default:current_timestamp" json:"updated_at"`
+}
+
+type TranslationDTO struct {
+	Title         string `json:"title"`
+	Content       string `json:"content"`
+	ContentFormat string `json:"content_format,omitempty"`
+}
+
+// SlugAlias keeps a previous slug of a news so old links can be redirected.
+type SlugAlias struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	NewsID    uint      `gorm:"not null;index" json:"news_id"`
+	Slug      string    `gorm:"not null;type:varchar(255);uniqueIndex" json:"slug"`
+	CreatedAt time.Time `gorm:"default:current_timestamp" json:"created_at"`
+}
+
+// Revision is a snapshot of a news taken on every save.
+type Revision struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	NewsID    uint      `gorm:"not null;index" json:"news_id"`
+	Title     string    `gorm:"not null" json:"title"`
+	Content   string    `gorm:"not null" json:"content"`
+	Status    string    `gorm:"not null;type:varchar(20)" json:"status"`
+	TagIDs    UintList  `gorm:"type:text" json:"tag_ids"`
+	TopicID   uint      `json:"topic_id"`
+	Author    string    `gorm:"type:varchar(100)" json:"author"`
+	CreatedAt time.Time `gorm:"default:current_timestamp" json:"created_at"`
+}
+
+type RevisionDiff struct {
+	From    uint              `json:"from"`
+	To      uint              `json:"to"`
+	Title   []tools.DiffChunk `json:"title"`
+	Content []tools.DiffChunk `json:"content"`
+}
+
+// UintList is stored as a json array.
+type UintList []uint
+
+func (l UintList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal([]uint(l))
+	return string(b), err
+}
+
+func (l *UintList) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	case []byte:
+		return json.Unmarshal(v, l)
+	}
+	return fmt.Errorf("unsupported type %T for UintList", src)
 }
 
 type NewsDTO struct {
-	ID      uint   `json:"id,omitempty"`
-	Title   string `json:"title,omitempty"`
-	Writer  string `json:"writer,omitempty"`
-	Content string `json:"content,omitempty"`
-	Status  string `json:"status,omitempty"`
-	Tags    []uint `json:"tags,omitempty"`
-	TopicID uint   `json:"topic_id,omitempty"`
+	ID        uint       `json:"id,omitempty"`
+	Title     string     `json:"title,omitempty"`
+	Slug      string     `json:"slug,omitempty"`
+	Writer    string     `json:"writer,omitempty"`
+	WriterID  uint       `json:"writer_id,omitempty"`
+	Content   string     `json:"content,omitempty"`
+	Status    string     `json:"status,omitempty"`
+	Tags      []uint     `json:"tags,omitempty"`
+	TopicID   uint       `json:"topic_id,omitempty"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+
+	// TagNames are added to Tags, the tags missing are created.
+	TagNames []string `json:"tag_names,omitempty"`
+
+	// Media are the attached media ids, CoverID is the cover image, zero has none.
+	Media   []uint `json:"media,omitempty"`
+	CoverID uint   `json:"cover_id,omitempty"`
+
+	// UnpublishAt takes a published news down once passed, nil never expires.
+	UnpublishAt *time.Time `json:"unpublish_at,omitempty"`
+
+	// ContentFormat is plain, markdown or html, the current format is kept when empty.
+	ContentFormat string `json:"content_format,omitempty"`
+
+	// Locale is the language the news is written in, the current one is kept when empty.
+	Locale string `json:"locale,omitempty"`
+
+	// Reason explains a status change, sending a news back to draft needs one.
+	Reason string `json:"reason,omitempty"`
+
+	// Version is the version the client read, zero skips the check.
+	Version uint `json:"-"`
 }
 
 type Status string
 
 const (
-	StatusDraft   Status = "draft"
-	StatusPublish Status = "publish"
-	StatusDelete  Status = "deleted"
+	StatusDraft     Status = "draft"
+	StatusInReview  Status = "in_review"
+	StatusApproved  Status = "approved"
+	StatusPublish   Status = "publish"
+	StatusScheduled Status = "scheduled"
+	StatusArchived  Status = "archived"
+	StatusExpired   Status = "expired"
+	StatusDelete    Status = "deleted"
+)
+
+// transitions lists the allowed status changes of the editorial workflow,
+// moving back to draft from review is a rejection. Published news only
+// expire through the publisher once their unpublish time has passed.
+var transitions = map[Status][]Status{
+	StatusDraft:     {StatusInReview},
+	StatusInReview:  {StatusApproved, StatusDraft},
+	StatusApproved:  {StatusPublish, StatusScheduled, StatusDraft},
+	StatusScheduled: {StatusPublish, StatusDraft},
+	StatusPublish:   {StatusArchived},
+	StatusExpired:   {StatusDraft, StatusArchived},
+}
+
+// checkTransition applies the workflow rules shared by Save and Transition.
+func checkTransition(from, to Status, reason string) error {
+	if !CanTransition(from, to) {
+		return fmt.Errorf("%w from %s to %s", ErrInvalidTransition, from, to)
+	}
+
+	// rejection needs a reason
+	if to == StatusDraft && strings.TrimSpace(reason) == "" {
+		return ErrReasonRequired
+	}
+
+	return nil
+}
+
+func CanTransition(from, to Status) bool {
+	for _, v := range transitions[from] {
+		if v == to {
+			return true
+		}
+	}
+	return false
+}
+
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// ErrReasonRequired is returned when a news is sent back to draft without a reason.
+var ErrReasonRequired = errors.New("reason is required to reject a news")
+
+// ErrCreateStatus is returned when a news is created in another status than draft.
+var ErrCreateStatus = errors.New("new news start as draft, move them on with /submit")
+
+// checkUnpublishAt makes sure a news going live is not taken down before it is published.
+func checkUnpublishAt(publishAt time.Time, unpublishAt *time.Time) error {
+	if unpublishAt == nil {
+		return nil
+	}
+	if !unpublishAt.After(publishAt) || !unpublishAt.After(time.Now()) {
+		return fmt.Errorf("unpublish_at must be in the future and after publish_at")
+	}
+	return nil
+}
+
+// Transition records a status change of a news, who made it and why.
+type Transition struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	NewsID    uint      `gorm:"not null;index" json:"news_id"`
+	From      string    `gorm:"not null;type:varchar(20)" json:"from"`
+	To        string    `gorm:"not null;type:varchar(20)" json:"to"`
+	Reason    string    `json:"reason,omitempty"`
+	Actor     string    `gorm:"type:varchar(100)" json:"actor"`
+	CreatedAt time.Time `gorm:"default:current_timestamp" json:"created_at"`
+}
+
+type TransitionDTO struct {
+	Reason    string     `json:"reason,omitempty"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+}
+
+const (
+	BulkStatus     = "status"
+	BulkAddTags    = "add_tags"
+	BulkRemoveTags = "remove_tags"
+	BulkTopic      = "topic"
+	BulkDelete     = "delete"
+
+	// BulkTransaction rolls every operation back when one fails.
+	BulkTransaction = "transaction"
+	// BulkBestEffort keeps the operations that succeeded.
+	BulkBestEffort = "best_effort"
+
+	MaxBulkOperations = 500
 )
 
+var ErrBulkRolledBack = errors.New("bulk operations rolled back")
+
+// BulkOperation changes a single news, the fields used depend on Op.
+type BulkOperation struct {
+	Op        string     `json:"op"`
+	ID        uint       `json:"id"`
+	Status    string     `json:"status,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	Tags      []uint     `json:"tags,omitempty"`
+	TopicID   uint       `json:"topic_id,omitempty"`
+}
+
+type BulkDTO struct {
+	Mode       string          `json:"mode"`
+	Operations []BulkOperation `json:"operations"`
+}
+
+// Validate checks the shape of every operation before any of them runs.
+func (dto *BulkDTO) Validate() error {
+	if dto.Mode == "" {
+		dto.Mode = BulkTransaction
+	}
+	if dto.Mode != BulkTransaction && dto.Mode != BulkBestEffort {
+		return fmt.Errorf("unknown mode %q, allowed modes are %s, %s", dto.Mode, BulkTransaction, BulkBestEffort)
+	}
+
+	if len(dto.Operations) == 0 || len(dto.Operations) > MaxBulkOperations {
+		return fmt.Errorf("between 1 and %d operations are allowed", MaxBulkOperations)
+	}
+
+	for i, v := range dto.Operations {
+		if v.ID == 0 {
+			return fmt.Errorf("operation %d: id is required", i)
+		}
+
+		switch v.Op {
+		case BulkStatus:
+			if v.Status == "" {
+				return fmt.Errorf("operation %d: status is required", i)
+			}
+		case BulkAddTags, BulkRemoveTags:
+			if len(v.Tags) == 0 {
+				return fmt.Errorf("operation %d: tags are required", i)
+			}
+		case BulkTopic:
+			if v.TopicID == 0 {
+				return fmt.Errorf("operation %d: topic_id is required", i)
+			}
+		case BulkDelete:
+		default:
+			return fmt.Errorf("operation %d: unknown op %q", i, v.Op)
+		}
+	}
+
+	return nil
+}
+
+// BulkResult is the outcome of one operation, in the order they were sent.
+type BulkResult struct {
+	Op      string `json:"op"`
+	ID      uint   `json:"id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
 type Filter struct {
+	Q            string `form:"q"`
 	Status       string `form:"status"`
 	Topic        uint   `form:"topic"`
+	Writer       uint   `form:"writer"`
 	CreatedStart string `form:"created_start"`
 	CreatedEnd   string `form:"created_end"`
+	Sort         string `form:"sort"`
+	Trashed      bool   `form:"-"`
+
+	// comma separated tag ids and names, tag_match is any (the default) or all
+	Tags        string `form:"tags"`
+	TagNames    string `form:"tag_names"`
+	TagMatch    string `form:"tag_match"`
+	ExcludeTags string `form:"exclude_tags"`
+
+	// tag filters split by ParseTags
+	TagIDs        []uint   `form:"-"`
+	TagNameList   []string `form:"-"`
+	ExcludeTagIDs []uint   `form:"-"`
+
+	pagination.Pagination
 }
 
+const (
+	TagMatchAny = "any"
+	TagMatchAll = "all"
+
+	maxFilterTags = 20
+)
+
+// ParseTags validates the tag filters and splits their lists.
+func (f *Filter) ParseTags() (err error) {
+	if f.TagMatch == "" {
+		f.TagMatch = TagMatchAny
+	}
+	if f.TagMatch != TagMatchAny && f.TagMatch != TagMatchAll {
+		return fmt.Errorf("unknown tag_match %q, allowed values are %s, %s", f.TagMatch, TagMatchAny, TagMatchAll)
+	}
+
+	if f.TagIDs, err = tools.StringsToUints(f.Tags); err != nil {
+		return fmt.Errorf("tags: %w", err)
+	}
+	if f.ExcludeTagIDs, err = tools.StringsToUints(f.ExcludeTags); err != nil {
+		return fmt.Errorf("exclude_tags: %w", err)
+	}
+
+	f.TagNameList = nil
+	for _, v := range strings.Split(f.TagNames, ",") {
+		if v = tag.TagKey(v); v != "" {
+			f.TagNameList = append(f.TagNameList, v)
+		}
+	}
+
+	if len(f.TagIDs)+len(f.TagNameList) > maxFilterTags || len(f.ExcludeTagIDs) > maxFilterTags {
+		return fmt.Errorf("at most %d tags can be filtered on", maxFilterTags)
+	}
+
+	return nil
+}
+
+// SortFields lists the fields a listing can be sorted by.
+var SortFields = map[string]string{
+	"id":         "id",
+	"title":      "title",
+	"writer":     "writer",
+	"writer_id":  "writer_id",
+	"status":     "status",
+	"topic_id":   "topic_id",
+	"publish_at": "publish_at",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+	"deleted_at": "deleted_at",
+}
+
+const (
+	DefaultRelatedLimit = 5
+	MaxRelatedLimit     = 20
+)
+
 type ContextKey string
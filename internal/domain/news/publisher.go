@@ -0,0 +1,68 @@
+package news
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ntm/internal/infrastructure/cache"
+)
+
+// Publisher periodically publishes scheduled news once their publish time has
+// passed and takes down published news once their unpublish time has passed.
+type Publisher struct {
+	newsUseCase UseCase
+	cacher      cache.Cacher
+	interval    time.Duration
+}
+
+func NewPublisher(newsUseCase UseCase, cacher cache.Cacher, interval time.Duration) *Publisher {
+	return &Publisher{
+		newsUseCase: newsUseCase,
+		cacher:      cacher,
+		interval:    interval,
+	}
+}
+
+// Run blocks until ctx is cancelled.
+func (p *Publisher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.publish(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Publisher) publish(ctx context.Context) {
+	published, err := p.newsUseCase.PublishScheduled(ctx)
+	if err != nil {
+		log.Println(err.Error())
+	}
+	if len(published) > 0 {
+		log.Printf("news | publisher | published %v", published)
+	}
+
+	expired, err := p.newsUseCase.UnpublishExpired(ctx)
+	if err != nil {
+		log.Println(err.Error())
+	}
+	if len(expired) > 0 {
+		log.Printf("news | publisher | expired %v", expired)
+	}
+
+	if len(published) == 0 && len(expired) == 0 {
+		return
+	}
+
+	// flush cache
+	if err := p.cacher.Flush(); err != nil {
+		log.Println(err.Error())
+	}
+}
@@ -0,0 +1,60 @@
+package news
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ntm/internal/infrastructure/cache"
+)
+
+// Purger periodically removes news that stayed in the trash longer than the retention period.
+type Purger struct {
+	newsUseCase UseCase
+	cacher      cache.Cacher
+	retention   time.Duration
+	interval    time.Duration
+}
+
+func NewPurger(newsUseCase UseCase, cacher cache.Cacher, retention time.Duration, interval time.Duration) *Purger {
+	return &Purger{
+		newsUseCase: newsUseCase,
+		cacher:      cacher,
+		retention:   retention,
+		interval:    interval,
+	}
+}
+
+// Run blocks until ctx is cancelled.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Purger) purge(ctx context.Context) {
+	purged, err := p.newsUseCase.PurgeTrash(ctx, time.Now().Add(-p.retention))
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+
+	if purged == 0 {
+		return
+	}
+	log.Printf("news | purger | purged %d news", purged)
+
+	// flush cache
+	if err := p.cacher.Flush(); err != nil {
+		log.Println(err.Error())
+	}
+}
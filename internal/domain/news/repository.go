@@ -2,17 +2,69 @@ package news
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/ntm/internal/pkg/common/http/etag"
+	"github.com/ntm/internal/pkg/common/pagination"
+	"github.com/ntm/internal/pkg/common/sorting"
+	"github.com/ntm/internal/tools"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// searchVector is the document of the full text search index,
+// queries must use the exact same expression to hit the index.
+const searchVector = "setweight(to_tsvector('simple', coalesce(title, '')), 'A') || setweight(to_tsvector('simple', coalesce(content, '')), 'B')"
+
+// SearchIndex creates the full text search index over title and content.
+const SearchIndex = "CREATE INDEX IF NOT EXISTS idx_news_search ON news USING gin ((" + searchVector + "))"
+
+// ClearZeroDeletedAt resets the zero deleted_at written by updates before
+// soft delete was introduced, those rows would otherwise look deleted.
+const ClearZeroDeletedAt = "UPDATE news SET deleted_at = NULL WHERE deleted_at < '0002-01-01'"
+
 type Repository interface {
 	GetAll(ctx context.Context) ([]News, error)
+	Count(ctx context.Context) (int64, error)
 	GetByID(ctx context.Context, id int) (News, error)
+	GetBySlug(ctx context.Context, slug string) (News, error)
+	GetRelated(ctx context.Context, model News, limit int) ([]News, error)
+	GetSlugAlias(ctx context.Context, slug string) (SlugAlias, error)
+	SlugExists(ctx context.Context, slug string, newsID uint) (bool, error)
+	AddSlugAlias(ctx context.Context, model SlugAlias) error
+	DeleteSlugAlias(ctx context.Context, newsID uint, slug string) error
+	GetWithoutSlug(ctx context.Context) ([]News, error)
+	SetSlug(ctx context.Context, id uint, slug string) error
+	GetFingerprints(ctx context.Context, since time.Time) ([]News, error)
+	GetFingerprintsByBands(ctx context.Context, since time.Time, fingerprint int64, bands int) ([]News, error)
+	CreateBandIndexes(ctx context.Context, bands int) error
+	GetWithoutSummary(ctx context.Context) ([]News, error)
+	SetSummary(ctx context.Context, id uint, contentHTML string, excerpt string, words int, minutes int) error
+	GetTranslationsWithoutSummary(ctx context.Context) ([]Translation, error)
+	SetTranslationSummary(ctx context.Context, id uint, excerpt string, words int, minutes int) error
+	GetWithoutFingerprint(ctx context.Context) ([]News, error)
+	SetFingerprint(ctx context.Context, id uint, fingerprint int64) error
 	Upsert(ctx context.Context, model News) (News, error)
+	PublishScheduled(ctx context.Context, now time.Time) ([]uint, error)
+	UnpublishExpired(ctx context.Context, now time.Time) ([]uint, error)
+	GetTranslations(ctx context.Context, newsIDs []uint, locales []string) ([]Translation, error)
+	UpsertTranslation(ctx context.Context, model Translation) (Translation, error)
+	DeleteTranslation(ctx context.Context, newsID int, locale string) (bool, error)
+	GetTrashedByID(ctx context.Context, id int) (News, error)
+	GetTrashedBefore(ctx context.Context, before time.Time) ([]uint, error)
+	Restore(ctx context.Context, id uint, status Status) error
+	Purge(ctx context.Context, ids []uint) (int64, error)
+	UpdateStatus(ctx context.Context, id uint, from Status, to Status, publishAt time.Time) (bool, error)
+	AddTransition(ctx context.Context, model Transition) (Transition, error)
+	GetTransitions(ctx context.Context, newsID int) ([]Transition, error)
+	AddRevision(ctx context.Context, model Revision) (Revision, error)
+	GetRevisions(ctx context.Context, newsID int) ([]Revision, error)
+	GetRevision(ctx context.Context, newsID int, id int) (Revision, error)
+	MigrateWriters(ctx context.Context) error
 	GetDB() *gorm.DB
 }
 
@@ -25,54 +77,493 @@ func NewRepository(db *gorm.DB) Repository {
 }
 
 func (r *repository) GetAll(ctx context.Context) (res []News, err error) {
-	exec := r.db.Preload("Topic").Preload("Tags")
 	filter := ctx.Value(ContextKey("news_filter")).(Filter)
+	exec := r.filter(r.db.Preload("Topic").Preload("Tags").Preload("WriterProfile").Preload("Cover"), filter)
+
+	if filter.Q != "" {
+		exec = exec.Select(
+			"news.*, ts_rank("+searchVector+", websearch_to_tsquery('simple', @q)) as search_rank, "+
+				"ts_headline('simple', title, websearch_to_tsquery('simple', @q), 'HighlightAll=true') as title_snippet, "+
+				"ts_headline('simple', content, websearch_to_tsquery('simple', @q), 'MaxFragments=2, MaxWords=30, MinWords=10') as snippet",
+			sql.Named("q", filter.Q),
+		)
+
+		// order by relevance unless the client asks for another order
+		if filter.Sort == "" {
+			exec = exec.Order("search_rank desc")
+		}
+	}
+
+	err = exec.Scopes(sorting.Scope(filter.Sort, SortFields), pagination.Scope(filter.Pagination, "id")).Find(&res).Error
+	if err != nil {
+		if errors.Is(gorm.ErrRecordNotFound, err) {
+			return res, fmt.Errorf("record not found")
+		}
+		return res, err
+	}
+
+	if filter.IsBackward() {
+		for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+			res[i], res[j] = res[j], res[i]
+		}
+	}
+
+	return res, nil
+}
+
+func (r *repository) Count(ctx context.Context) (total int64, err error) {
+	filter := ctx.Value(ContextKey("news_filter")).(Filter)
+	err = r.filter(r.db.Model(&News{}), filter).Count(&total).Error
+	return total, err
+}
+
+// filter applies the listing conditions shared by GetAll and Count.
+func (r *repository) filter(exec *gorm.DB, filter Filter) *gorm.DB {
+	if filter.Trashed {
+		exec = exec.Unscoped().Where("deleted_at is not null")
+	}
 
 	var dateValid bool
 	_, a := time.Parse("2006-01-02", filter.CreatedStart)
-	_, b := time.Parse("2006-01-02", filter.CreatedStart)
+	_, b := time.Parse("2006-01-02", filter.CreatedEnd)
 	if a == nil && b == nil {
 		dateValid = true
 	}
 
 	if dateValid {
-		exec.Where("to_char(created_at, 'YYYY-MM-DD') between ? and ?", filter.CreatedStart, filter.CreatedEnd)
+		exec = exec.Where("to_char(created_at, 'YYYY-MM-DD') between ? and ?", filter.CreatedStart, filter.CreatedEnd)
+	}
+
+	if filter.Q != "" {
+		exec = exec.Where("("+searchVector+") @@ websearch_to_tsquery('simple', ?)", filter.Q)
 	}
 
 	if filter.Status != "" {
-		if filter.Topic != 0 {
-			err = exec.Find(&res, "status = ? and topic_id = ?", filter.Status, filter.Topic).Error
-		} else {
-			err = exec.Find(&res, "status = ?", filter.Status).Error
+		exec = exec.Where("status = ?", filter.Status)
+	}
+
+	if filter.Topic != 0 {
+		exec = exec.Where("topic_id = ?", filter.Topic)
+	}
+
+	if filter.Writer != 0 {
+		exec = exec.Where("writer_id = ?", filter.Writer)
+	}
+
+	// tags by id or by name or alias, all needs one match per tag
+	const tagged = "EXISTS (SELECT 1 FROM news_tags JOIN tags ON tags.id = news_tags.tag_id WHERE news_tags.news_id = news.id AND "
+	const aliased = "tags.id IN (SELECT tag_id FROM tag_aliases WHERE alias_key "
+	if filter.TagMatch == TagMatchAll {
+		for _, v := range filter.TagIDs {
+			exec = exec.Where(tagged+"tags.id = ?)", v)
+		}
+		for _, v := range filter.TagNameList {
+			exec = exec.Where(tagged+"(tags.tag_key = ? OR "+aliased+"= ?)))", v, v)
 		}
-	} else {
-		if filter.Topic != 0 {
-			err = exec.Find(&res, "topic_id = ?", filter.Topic).Error
-		} else {
-			err = exec.Find(&res).Error
+	} else if len(filter.TagIDs) > 0 || len(filter.TagNameList) > 0 {
+		var conds []string
+		var args []interface{}
+		if len(filter.TagIDs) > 0 {
+			conds, args = append(conds, "tags.id in ?"), append(args, filter.TagIDs)
 		}
+		if len(filter.TagNameList) > 0 {
+			conds, args = append(conds, "tags.tag_key in ?", aliased+"in ?)"), append(args, filter.TagNameList, filter.TagNameList)
+		}
+		exec = exec.Where(tagged+"("+strings.Join(conds, " OR ")+"))", args...)
 	}
 
-	if err != nil {
-		if errors.Is(gorm.ErrRecordNotFound, err) {
-			return res, fmt.Errorf("record not found")
-		}
-		return res, err
+	if len(filter.ExcludeTagIDs) > 0 {
+		exec = exec.Where("NOT EXISTS (SELECT 1 FROM news_tags WHERE news_tags.news_id = news.id AND news_tags.tag_id in ?)", filter.ExcludeTagIDs)
 	}
 
-	return res, nil
+	return exec
 }
 
 func (r *repository) GetByID(ctx context.Context, id int) (res News, err error) {
-	result := r.db.Where("id = ?", id).Preload("Topic").Preload("Tags").Find(&res)
+	result := r.db.Where("id = ?", id).Preload("Topic").Preload("Tags").Preload("WriterProfile").Preload("Cover").Preload("Media").Find(&res)
+	return res, result.Error
+}
+
+// related articles scoring, every shared tag weighs twice as much as the
+// same topic and the score halves every relatedHalfLife days since publication.
+const (
+	relatedTagWeight    = 2
+	relatedTopicWeight  = 1
+	relatedHalfLifeDays = 30
+)
+
+// GetRelated scores the other published news sharing a tag or the topic of the given news.
+func (r *repository) GetRelated(ctx context.Context, model News, limit int) (res []News, err error) {
+	tagIDs := []uint{}
+	for _, v := range model.Tags {
+		tagIDs = append(tagIDs, v.ID)
+	}
+
+	const tagged = "EXISTS (SELECT 1 FROM news_tags WHERE news_tags.news_id = news.id AND news_tags.tag_id IN @tags)"
+
+	// news without a topic only relate through their tags
+	score := "(SELECT count(*) FROM news_tags WHERE news_tags.news_id = news.id AND news_tags.tag_id IN @tags) * @tag_weight"
+	where := tagged
+	if model.TopicID != 0 {
+		score += " + CASE WHEN news.topic_id = @topic THEN @topic_weight ELSE 0 END"
+		where = "news.topic_id = @topic OR " + tagged
+	}
+	score = "(" + score + ") * power(0.5, extract(epoch from now() - news.publish_at) / 86400 / @half_life)"
+
+	args := []interface{}{
+		sql.Named("tags", tagIDs), sql.Named("topic", model.TopicID),
+		sql.Named("tag_weight", relatedTagWeight), sql.Named("topic_weight", relatedTopicWeight),
+		sql.Named("half_life", relatedHalfLifeDays),
+	}
+
+	err = r.db.Preload("Topic").Preload("Tags").Preload("WriterProfile").Preload("Cover").
+		Select("news.*, "+score+" as related_score", args...).
+		Where("id <> ? and status = ?", model.ID, StatusPublish).
+		Where(where, args...).
+		Order("related_score desc, publish_at desc").
+		Limit(limit).
+		Find(&res).Error
+	return res, err
+}
+
+func (r *repository) GetBySlug(ctx context.Context, slug string) (res News, err error) {
+	result := r.db.Where("slug = ?", slug).Preload("Topic").Preload("Tags").Preload("WriterProfile").Preload("Cover").Preload("Media").First(&res)
+	return res, result.Error
+}
+
+func (r *repository) GetSlugAlias(ctx context.Context, slug string) (res SlugAlias, err error) {
+	result := r.db.Where("slug = ?", slug).First(&res)
+	return res, result.Error
+}
+
+// SlugExists reports whether a slug is taken by another news, either as
+// its current slug or as an alias. Trashed news keep their slugs.
+func (r *repository) SlugExists(ctx context.Context, slug string, newsID uint) (bool, error) {
+	var count int64
+	result := r.db.Unscoped().Model(&News{}).Where("slug = ? and id <> ?", slug, newsID).Count(&count)
+	if result.Error != nil || count > 0 {
+		return count > 0, result.Error
+	}
+
+	result = r.db.Model(&SlugAlias{}).Where("slug = ? and news_id <> ?", slug, newsID).Count(&count)
+	return count > 0, result.Error
+}
+
+func (r *repository) AddSlugAlias(ctx context.Context, model SlugAlias) error {
+	result := r.db.Create(&model)
+	return result.Error
+}
+
+func (r *repository) DeleteSlugAlias(ctx context.Context, newsID uint, slug string) error {
+	result := r.db.Where("news_id = ? and slug = ?", newsID, slug).Delete(&SlugAlias{})
+	return result.Error
+}
+
+func (r *repository) GetWithoutSlug(ctx context.Context) (res []News, err error) {
+	result := r.db.Unscoped().Where("slug is null or slug = ''").Order("id").Find(&res)
+	return res, result.Error
+}
+
+// SetSlug only touches the slug column, it is used to backfill existing news.
+func (r *repository) SetSlug(ctx context.Context, id uint, slug string) error {
+	result := r.db.Unscoped().Model(&News{}).Where("id = ?", id).Update("slug", slug)
+	return result.Error
+}
+
+// GetFingerprints returns the id and fingerprint of the news created since
+// the given time, a zero time returns every news.
+func (r *repository) GetFingerprints(ctx context.Context, since time.Time) (res []News, err error) {
+	exec := r.db.Select("id", "fingerprint").Where("fingerprint <> 0")
+	if !since.IsZero() {
+		exec = exec.Where("created_at >= ?", since)
+	}
+	result := exec.Order("id").Find(&res)
+	return res, result.Error
+}
+
+// GetWithoutSummary returns the news saved before rendered contents and summaries were stored.
+func (r *repository) GetWithoutSummary(ctx context.Context) (res []News, err error) {
+	result := r.db.Unscoped().Where("content <> '' and (content_html is null or excerpt is null)").Order("id").Find(&res)
+	return res, result.Error
+}
+
+// SetSummary only touches the rendered content and its summary, it is used to backfill existing news.
+func (r *repository) SetSummary(ctx context.Context, id uint, contentHTML string, excerpt string, words int, minutes int) error {
+	result := r.db.Unscoped().Model(&News{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+		"content_html": contentHTML,
+		"excerpt":      excerpt,
+		"word_count":   words,
+		"reading_time": minutes,
+	})
+	return result.Error
+}
+
+// GetTranslationsWithoutSummary returns the translations saved before summaries were stored.
+func (r *repository) GetTranslationsWithoutSummary(ctx context.Context) (res []Translation, err error) {
+	result := r.db.Where("content_html <> '' and excerpt is null").Order("id").Find(&res)
 	return res, result.Error
 }
 
+// SetTranslationSummary only touches the summary of a translation, it is used to backfill existing translations.
+func (r *repository) SetTranslationSummary(ctx context.Context, id uint, excerpt string, words int, minutes int) error {
+	result := r.db.Model(&Translation{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+		"excerpt":      excerpt,
+		"word_count":   words,
+		"reading_time": minutes,
+	})
+	return result.Error
+}
+
+// GetFingerprintsByBands returns the id and fingerprint of the news created since
+// the given time sharing at least one of the bands of a fingerprint.
+func (r *repository) GetFingerprintsByBands(ctx context.Context, since time.Time, fingerprint int64, bands int) (res []News, err error) {
+	conds := make([]string, 0, bands)
+	args := make([]interface{}, 0, bands)
+	for i, v := range tools.Bands(uint64(fingerprint), bands) {
+		conds = append(conds, bandExpr(i, bands)+" = ?")
+		args = append(args, int64(v))
+	}
+
+	result := r.db.Select("id", "fingerprint").
+		Where("fingerprint <> 0 and created_at >= ?", since).
+		Where(strings.Join(conds, " OR "), args...).
+		Order("id").Find(&res)
+	return res, result.Error
+}
+
+// CreateBandIndexes indexes every band of the fingerprints so GetFingerprintsByBands
+// only reads the news sharing a band.
+func (r *repository) CreateBandIndexes(ctx context.Context, bands int) error {
+	for i := 0; i < bands; i++ {
+		stmt := fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_news_fingerprint_%d_%d ON news ((%s)) WHERE fingerprint <> 0", bands, i, bandExpr(i, bands))
+		if err := r.db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bandExpr reads band i of n from the fingerprint column, the same way tools.Bands does.
+func bandExpr(i, n int) string {
+	shift, mask := tools.Band(i, n)
+	return fmt.Sprintf("((fingerprint >> %d) & %d)", shift, mask)
+}
+
+func (r *repository) GetWithoutFingerprint(ctx context.Context) (res []News, err error) {
+	result := r.db.Where("fingerprint is null").Order("id").Find(&res)
+	return res, result.Error
+}
+
+// SetFingerprint only touches the fingerprint column, it is used to backfill existing news.
+func (r *repository) SetFingerprint(ctx context.Context, id uint, fingerprint int64) error {
+	result := r.db.Model(&News{}).Where("id = ?", id).Update("fingerprint", fingerprint)
+	return result.Error
+}
+
 func (r *repository) Upsert(ctx context.Context, model News) (res News, err error) {
-	result := r.db.Save(&model)
+	if model.ID == 0 {
+		result := r.db.Save(&model)
+		return model, result.Error
+	}
+
+	// only update the row if nobody else did since it was read
+	version := model.Version
+	model.Version++
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model).Where("version = ?", version).Select("*").Updates(&model)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return etag.ErrPreconditionFailed
+		}
+
+		// saving only links new tags and media, unlink the ones no longer on the news
+		tagIDs := []uint{0}
+		for _, v := range model.Tags {
+			tagIDs = append(tagIDs, v.ID)
+		}
+		if err := tx.Exec("DELETE FROM news_tags WHERE news_id = ? AND tag_id NOT IN ?", model.ID, tagIDs).Error; err != nil {
+			return err
+		}
+
+		mediaIDs := []uint{0}
+		for _, v := range model.Media {
+			mediaIDs = append(mediaIDs, v.ID)
+		}
+		return tx.Exec("DELETE FROM news_media WHERE news_id = ? AND media_id NOT IN ?", model.ID, mediaIDs).Error
+	})
+
+	return model, err
+}
+
+// PublishScheduled publishes every scheduled news that is due and returns their ids.
+// The status check and the update happen in a single statement, when several
+// instances run it at once postgres re-checks the condition on the locked rows,
+// so each news is published by exactly one of them.
+func (r *repository) PublishScheduled(ctx context.Context, now time.Time) (ids []uint, err error) {
+	result := r.db.WithContext(ctx).Raw(
+		"UPDATE news SET status = ?, updated_at = ?, version = version + 1 WHERE status = ? AND publish_at <= ? AND deleted_at IS NULL RETURNING id",
+		StatusPublish, now, StatusScheduled, now,
+	).Scan(&ids)
+	return ids, result.Error
+}
+
+// UnpublishExpired moves every published news past its unpublish time to
+// expired and returns their ids, like PublishScheduled it is safe to run concurrently.
+func (r *repository) UnpublishExpired(ctx context.Context, now time.Time) (ids []uint, err error) {
+	result := r.db.WithContext(ctx).Raw(
+		"UPDATE news SET status = ?, updated_at = ?, version = version + 1 WHERE status = ? AND unpublish_at <= ? AND deleted_at IS NULL RETURNING id",
+		StatusExpired, now, StatusPublish, now,
+	).Scan(&ids)
+	return ids, result.Error
+}
+
+func (r *repository) GetTrashedByID(ctx context.Context, id int) (res News, err error) {
+	result := r.db.Unscoped().Where("id = ? and deleted_at is not null", id).Preload("Topic").Preload("Tags").Preload("WriterProfile").Preload("Cover").Preload("Media").First(&res)
+	return res, result.Error
+}
+
+func (r *repository) GetTrashedBefore(ctx context.Context, before time.Time) (ids []uint, err error) {
+	result := r.db.Unscoped().Model(&News{}).Where("deleted_at < ?", before).Pluck("id", &ids)
+	return ids, result.Error
+}
+
+func (r *repository) Restore(ctx context.Context, id uint, status Status) error {
+	result := r.db.Unscoped().Model(&News{}).
+		Where("id = ? and deleted_at is not null", id).
+		Updates(map[string]interface{}{"status": status, "deleted_at": nil, "updated_at": time.Now(), "version": gorm.Expr("version + 1")})
+	return result.Error
+}
+
+// Purge permanently removes trashed news together with their tags, media links, revisions, transitions, slug aliases and translations.
+func (r *repository) Purge(ctx context.Context, ids []uint) (purged int64, err error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		// only news that are still in the trash
+		var trashed []uint
+		if err := tx.Unscoped().Model(&News{}).Where("id in ? and deleted_at is not null", ids).Pluck("id", &trashed).Error; err != nil {
+			return err
+		}
+		if len(trashed) == 0 {
+			return nil
+		}
+
+		if err := tx.Exec("DELETE FROM news_tags WHERE news_id in ?", trashed).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM news_media WHERE news_id in ?", trashed).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("news_id in ?", trashed).Delete(&Revision{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("news_id in ?", trashed).Delete(&Transition{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("news_id in ?", trashed).Delete(&SlugAlias{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("news_id in ?", trashed).Delete(&Translation{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Unscoped().Where("id in ?", trashed).Delete(&News{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+
+	return purged, err
+}
+
+// UpdateStatus moves a news from one status to another, it reports false
+// when the news is no longer in the expected status.
+func (r *repository) UpdateStatus(ctx context.Context, id uint, from Status, to Status, publishAt time.Time) (bool, error) {
+	result := r.db.Model(&News{}).
+		Where("id = ? and status = ?", id, from).
+		Updates(map[string]interface{}{"status": to, "publish_at": publishAt, "updated_at": time.Now(), "version": gorm.Expr("version + 1")})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *repository) AddTransition(ctx context.Context, model Transition) (res Transition, err error) {
+	result := r.db.Create(&model)
 	return model, result.Error
 }
 
+func (r *repository) GetTransitions(ctx context.Context, newsID int) (res []Transition, err error) {
+	result := r.db.Where("news_id = ?", newsID).Order("id").Find(&res)
+	return res, result.Error
+}
+
+func (r *repository) AddRevision(ctx context.Context, model Revision) (res Revision, err error) {
+	result := r.db.Create(&model)
+	return model, result.Error
+}
+
+func (r *repository) GetRevisions(ctx context.Context, newsID int) (res []Revision, err error) {
+	result := r.db.Where("news_id = ?", newsID).Order("id desc").Find(&res)
+	return res, result.Error
+}
+
+func (r *repository) GetRevision(ctx context.Context, newsID int, id int) (res Revision, err error) {
+	result := r.db.Where("news_id = ?", newsID).First(&res, id)
+	return res, result.Error
+}
+
+// GetTranslations returns the translations of the given news in any of the given locales,
+// no locale returns every translation.
+func (r *repository) GetTranslations(ctx context.Context, newsIDs []uint, locales []string) (res []Translation, err error) {
+	exec := r.db.Where("news_id in ?", newsIDs)
+	if len(locales) > 0 {
+		exec = exec.Where("locale in ?", locales)
+	}
+	err = exec.Order("news_id, locale").Find(&res).Error
+	return res, err
+}
+
+// UpsertTranslation adds the translation of a news or replaces the existing one in the same locale.
+func (r *repository) UpsertTranslation(ctx context.Context, model Translation) (res Translation, err error) {
+	err = r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "news_id"}, {Name: "locale"}},
+		DoUpdates: clause.AssignmentColumns([]string{"title", "content", "content_format", "content_html", "excerpt", "word_count", "reading_time", "updated_at"}),
+	}).Create(&model).Error
+	return model, err
+}
+
+func (r *repository) DeleteTranslation(ctx context.Context, newsID int, locale string) (bool, error) {
+	result := r.db.Where("news_id = ? and locale = ?", newsID, locale).Delete(&Translation{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// MigrateWriters creates a writer for every distinct free text writer name
+// and links the news to it, names differing only in case or spacing share a writer.
+func (r *repository) MigrateWriters(ctx context.Context) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`
+			INSERT INTO writers (name, name_key)
+			SELECT DISTINCT ON (name_key) name, name_key FROM (
+				SELECT regexp_replace(trim(writer), '\s+', ' ', 'g') AS name,
+					lower(regexp_replace(trim(writer), '\s+', ' ', 'g')) AS name_key
+				FROM news WHERE writer_id IS NULL AND trim(writer) <> ''
+			) names
+			ORDER BY name_key, name
+			ON CONFLICT (name_key) DO NOTHING`,
+		).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Exec(`
+			UPDATE news SET writer_id = writers.id FROM writers
+			WHERE news.writer_id IS NULL
+				AND writers.name_key = lower(regexp_replace(trim(news.writer), '\s+', ' ', 'g'))`,
+		).Error
+	})
+}
+
 func (r *repository) GetDB() *gorm.DB {
 	return r.db
 }
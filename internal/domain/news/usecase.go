@@ -2,18 +2,51 @@ package news
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/ntm/internal/domain/media"
 	"github.com/ntm/internal/domain/tag"
 	"github.com/ntm/internal/domain/topic"
+	"github.com/ntm/internal/domain/writer"
+	"github.com/ntm/internal/pkg/common/http/etag"
+	"github.com/ntm/internal/pkg/common/http/locale"
+	"github.com/ntm/internal/pkg/common/patch"
+	"github.com/ntm/internal/tools"
+	"gorm.io/gorm"
 )
 
 type UseCase interface {
-	FindAll(context context.Context) ([]News, error)
+	FindAll(context context.Context) ([]News, int64, error)
 	FindByID(context context.Context, id int) (News, error)
+	FindBySlug(context context.Context, slug string) (News, string, error)
+	FindRelated(context context.Context, id int, limit int) ([]News, error)
+	BackfillSlugs(context context.Context) error
+	BackfillContent(context context.Context) error
+	IndexFingerprints(context context.Context) error
+	BackfillFingerprints(context context.Context) error
+	FindDuplicates(context context.Context, threshold float64) ([]DuplicateCluster, error)
+	MigrateWriters(context context.Context) error
+	Patch(context context.Context, id int, version uint, contentType string, body []byte) (News, error)
 	Save(context context.Context, model NewsDTO) (News, error)
-	Delete(context context.Context, id int) error
+	Delete(context context.Context, id int, version uint) error
+	Bulk(context context.Context, dto BulkDTO) ([]BulkResult, error)
+	Transition(context context.Context, id int, to Status, reason string, publishAt *time.Time) (News, error)
+	FindTransitions(context context.Context, id int) ([]Transition, error)
+	Restore(context context.Context, id int) (News, error)
+	Purge(context context.Context, id int) error
+	PurgeTrash(context context.Context, before time.Time) (int64, error)
+	PublishScheduled(context context.Context) ([]uint, error)
+	UnpublishExpired(context context.Context) ([]uint, error)
+	FindRevisions(context context.Context, id int) ([]Revision, error)
+	DiffRevisions(context context.Context, id int, from int, to int) (RevisionDiff, error)
+	RestoreRevision(context context.Context, id int, revisionID int) (News, error)
+	FindTranslations(context context.Context, id int) ([]Translation, error)
+	SaveTranslation(context context.Context, id int, lang string, dto TranslationDTO) (Translation, error)
+	DeleteTranslation(context context.Context, id int, lang string) error
 }
 
 type useCase struct {
@@ -26,23 +59,98 @@ func NewUseCase(repo Repository) UseCase {
 	}
 }
 
-func (us *useCase) FindAll(context context.Context) (res []News, err error) {
+func (us *useCase) FindAll(context context.Context) (res []News, total int64, err error) {
 	res, err = us.repo.GetAll(context)
-	return res, err
+	if err != nil {
+		return res, total, err
+	}
+
+	if err = us.translate(context, res); err != nil {
+		return res, total, err
+	}
+
+	total, err = us.repo.Count(context)
+	return res, total, err
 }
 
 func (us *useCase) FindByID(context context.Context, id int) (res News, err error) {
 	res, err = us.repo.GetByID(context, id)
-	return res, err
+	if err != nil || res.ID == 0 {
+		return res, err
+	}
+
+	list := []News{res}
+	err = us.translate(context, list)
+	return list[0], err
 }
 
 func (us *useCase) Save(context context.Context, dto NewsDTO) (res News, err error) {
 	tagRepo := tag.NewRepository(us.repo.GetDB())
 	topicRepo := topic.NewRepository(us.repo.GetDB())
+	writerRepo := writer.NewRepository(us.repo.GetDB())
+	mediaRepo := media.NewRepository(us.repo.GetDB())
 
-	// status validation
-	if dto.Status != string(StatusDraft) && dto.Status != string(StatusPublish) {
-		return res, fmt.Errorf("invalid status parameter")
+	// get current state, new news starts as draft
+	current := News{Status: string(StatusDraft)}
+	if dto.ID != 0 {
+		current, err = us.repo.GetByID(context, int(dto.ID))
+		if err != nil {
+			return res, err
+		}
+		if current.ID == 0 {
+			return res, fmt.Errorf("record not found")
+		}
+		if dto.Version != 0 && dto.Version != current.Version {
+			return res, etag.ErrPreconditionFailed
+		}
+	}
+
+	// status validation, new news go through the workflow from draft
+	if dto.ID == 0 && dto.Status != "" && dto.Status != string(StatusDraft) {
+		return res, ErrCreateStatus
+	}
+	if dto.Status == "" {
+		dto.Status = current.Status
+	}
+	if dto.Status != current.Status {
+		if err = checkTransition(Status(current.Status), Status(dto.Status), dto.Reason); err != nil {
+			return res, err
+		}
+	}
+
+	// scheduled news needs a publish time in the future
+	if dto.Status == string(StatusScheduled) && dto.PublishAt == nil && current.Status == string(StatusScheduled) {
+		dto.PublishAt = &current.PublishAt
+	}
+	if dto.Status == string(StatusScheduled) && (dto.PublishAt == nil || !dto.PublishAt.After(time.Now())) {
+		return res, fmt.Errorf("scheduled news needs a future publish_at")
+	}
+
+	// render the content, html is only ever stored sanitized
+	if dto.ContentFormat == "" {
+		dto.ContentFormat = current.ContentFormat
+	}
+	if dto.ContentFormat == "" {
+		dto.ContentFormat = tools.FormatPlain
+	}
+	contentHTML, err := tools.RenderContent(dto.ContentFormat, dto.Content)
+	if err != nil {
+		return res, err
+	}
+	if dto.ContentFormat == tools.FormatHTML {
+		dto.Content = contentHTML
+	}
+
+	// the language the news is written in, translations cover the others
+	lang := current.Locale
+	if dto.Locale != "" {
+		lang = locale.Normalize(dto.Locale)
+		if lang == "" {
+			return res, fmt.Errorf("invalid locale %q", dto.Locale)
+		}
+	}
+	if lang == "" {
+		lang = DefaultLocale
 	}
 
 	// get tag list
@@ -52,49 +160,1055 @@ func (us *useCase) Save(context context.Context, dto NewsDTO) (res News, err err
 			tags = append(tags, tag)
 		}
 	}
+	// tags named but missing are only created with the news, once it is valid
+	var missing []string
+	for _, v := range dto.TagNames {
+		if tag.Name(v) == "" {
+			continue
+		}
+		t, err := tagRepo.GetByName(context, v)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if !containsName(missing, v) {
+				missing = append(missing, v)
+			}
+			continue
+		}
+		if err != nil {
+			return res, err
+		}
+		if !containsTag(tags, t.ID) {
+			tags = append(tags, t)
+		}
+	}
 
 	// get topic
 	topic, errTopic := topicRepo.GetByID(context, int(dto.TopicID))
-	if err != nil {
+	if errTopic != nil && dto.TopicID != 0 {
 		return res, errTopic
 	}
 
+	// get media, the cover must be an image
+	var attachments []media.Media
+	if len(dto.Media) > 0 {
+		attachments, err = mediaRepo.GetByIDs(context, dto.Media)
+		if err != nil {
+			return res, err
+		}
+		for _, id := range dto.Media {
+			if !containsMedia(attachments, id) {
+				return res, fmt.Errorf("media %d not found", id)
+			}
+		}
+	}
+
+	var coverID *uint
+	if dto.CoverID != 0 {
+		cover, err := mediaRepo.GetByID(context, int(dto.CoverID))
+		if err != nil {
+			return res, fmt.Errorf("cover %d: %w", dto.CoverID, err)
+		}
+		if !strings.HasPrefix(cover.MimeType, "image/") {
+			return res, fmt.Errorf("cover %d is not an image", dto.CoverID)
+		}
+		coverID = &cover.ID
+	}
+
+	// get writer, by id or by name, the current writer is kept when none is given
+	author := writer.Writer{ID: current.WriterID, Name: current.Writer}
+	if dto.WriterID != 0 {
+		author, err = writerRepo.GetByID(context, int(dto.WriterID))
+		if err != nil {
+			return res, err
+		}
+	} else if strings.TrimSpace(dto.Writer) != "" {
+		author, err = writerRepo.FirstOrCreate(context, dto.Writer)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	// generate slug, an explicit slug wins over the title
+	slug := current.Slug
+	if dto.Slug != "" {
+		slug = tools.Slugify(dto.Slug)
+	} else if current.Slug == "" || dto.Title != current.Title {
+		slug = tools.Slugify(dto.Title)
+	}
+	if slug == "" {
+		slug = "news"
+	}
+	if slug != current.Slug {
+		slug, err = us.uniqueSlug(context, slug, current.ID)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	excerpt, words, minutes := summarize(contentHTML)
 	news := News{
-		ID:      dto.ID,
-		Title:   dto.Title,
-		Writer:  dto.Writer,
-		Content: dto.Content,
-		Status:  dto.Status,
-		Tags:    tags,
-		Topic:   topic,
+		ID:            dto.ID,
+		Title:         dto.Title,
+		Slug:          slug,
+		Writer:        author.Name,
+		WriterID:      author.ID,
+		Content:       dto.Content,
+		ContentFormat: dto.ContentFormat,
+		ContentHTML:   contentHTML,
+		Excerpt:       excerpt,
+		WordCount:     words,
+		ReadingTime:   minutes,
+		Locale:        lang,
+		Status:        dto.Status,
+		Tags:          tags,
+		Topic:         topic,
+		CoverID:       coverID,
+		Media:         attachments,
+		PublishAt:     current.PublishAt,
+		CreatedAt:     current.CreatedAt,
+		Version:       current.Version,
 	}
 
-	if dto.Status == string(StatusPublish) {
+	if dto.Status == string(StatusPublish) && current.Status != string(StatusPublish) {
 		news.PublishAt = time.Now()
 	}
 
-	news, err = us.repo.Upsert(context, news)
+	if dto.Status == string(StatusScheduled) {
+		news.PublishAt = *dto.PublishAt
+	}
+
+	// a live news needs an unpublish time it has not reached yet
+	news.UnpublishAt = dto.UnpublishAt
+	if dto.Status == string(StatusPublish) || dto.Status == string(StatusScheduled) {
+		if err = checkUnpublishAt(news.PublishAt, news.UnpublishAt); err != nil {
+			return res, err
+		}
+	}
+
+	// near duplicates of recent news, only looked for when the content changes
+	news.Fingerprint = int64(tools.SimHash(tools.PlainText(contentHTML)))
+	var duplicates []uint
+	if contentHTML != current.ContentHTML {
+		duplicates, err = us.findDuplicates(context, news.Fingerprint, current.ID)
+		if err != nil {
+			return res, err
+		}
+		if len(duplicates) > 0 && DuplicateStrict {
+			return res, &DuplicateError{IDs: duplicates}
+		}
+	}
+
+	// the news, its new tags, slug alias, transition and revision are saved together
+	err = us.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		txRepo := NewRepository(tx)
+		txTagRepo := tag.NewRepository(tx)
+		for _, v := range missing {
+			t, err := txTagRepo.FirstOrCreate(context, v)
+			if err != nil {
+				return err
+			}
+			if !containsTag(news.Tags, t.ID) {
+				news.Tags = append(news.Tags, t)
+			}
+		}
+
+		news, err = txRepo.Upsert(context, news)
+		if err != nil {
+			return err
+		}
+
+		// keep the previous slug as an alias
+		if current.Slug != "" && current.Slug != news.Slug {
+			if err := txRepo.DeleteSlugAlias(context, news.ID, news.Slug); err != nil {
+				return err
+			}
+			if err := txRepo.AddSlugAlias(context, SlugAlias{NewsID: news.ID, Slug: current.Slug}); err != nil {
+				return err
+			}
+		}
+
+		// record transition
+		if dto.ID != 0 && news.Status != current.Status {
+			_, err := txRepo.AddTransition(context, Transition{
+				NewsID: news.ID,
+				From:   current.Status,
+				To:     news.Status,
+				Reason: dto.Reason,
+				Actor:  editor(context, news.Writer),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		// record revision
+		_, err := txRepo.AddRevision(context, newRevision(news, editor(context, news.Writer)))
+		return err
+	})
 	if err != nil {
 		return res, err
 	}
+	news.Duplicates = duplicates
 
 	return news, nil
 }
 
-func (us *useCase) Delete(context context.Context, id int) (err error) {
+// Patch applies a merge patch or a json patch on a news, only the fields in
+// the patch change and the patched news goes through the same validation as Save.
+func (us *useCase) Patch(context context.Context, id int, version uint, contentType string, body []byte) (res News, err error) {
+	news, err := us.repo.GetByID(context, id)
+	if err != nil {
+		return res, err
+	}
+	if news.ID == 0 {
+		return res, gorm.ErrRecordNotFound
+	}
+
+	doc, err := json.Marshal(newDTO(news))
+	if err != nil {
+		return res, err
+	}
+
+	patched, err := patch.Apply(contentType, doc, body)
+	if err != nil {
+		return res, err
+	}
+
+	var dto NewsDTO
+	if err = json.Unmarshal(patched, &dto); err != nil {
+		return res, fmt.Errorf("%w: %s", patch.ErrInvalidPatch, err.Error())
+	}
+	if strings.TrimSpace(dto.Title) == "" || strings.TrimSpace(dto.Content) == "" {
+		return res, fmt.Errorf("%w: title and content are required", patch.ErrInvalidPatch)
+	}
+
+	// a changed writer name links the news to that writer
+	if dto.Writer != "" && dto.WriterID == news.WriterID {
+		dto.WriterID = 0
+	}
+
+	dto.ID = news.ID
+	dto.Version = version
+	if _, err = us.Save(context, dto); err != nil {
+		return res, err
+	}
+
+	res, err = us.repo.GetByID(context, id)
+	return res, err
+}
+
+// Delete moves a news to the trash, unless version is zero it must match the stored version.
+func (us *useCase) Delete(context context.Context, id int, version uint) (err error) {
 	// check news is exist or not
 	var news News
 	news, err = us.repo.GetByID(context, id)
 	if err != nil {
 		return err
 	}
+	if news.ID == 0 {
+		return fmt.Errorf("record not found")
+	}
+	if version != 0 && version != news.Version {
+		return etag.ErrPreconditionFailed
+	}
 
+	// the news is trashed together with its transition
+	from := news.Status
 	news.Status = string(StatusDelete)
-	news.DeletedAt = time.Now()
-	news, err = us.repo.Upsert(context, news)
+	news.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	return us.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		txRepo := NewRepository(tx)
+		news, err := txRepo.Upsert(context, news)
+		if err != nil {
+			return err
+		}
+
+		// record transition
+		_, err = txRepo.AddTransition(context, Transition{
+			NewsID: news.ID,
+			From:   from,
+			To:     news.Status,
+			Actor:  editor(context, ""),
+		})
+		return err
+	})
+}
+
+// Transition moves a news through the editorial workflow,
+// publishAt is only used when scheduling.
+func (us *useCase) Transition(context context.Context, id int, to Status, reason string, publishAt *time.Time) (res News, err error) {
+	news, err := us.repo.GetByID(context, id)
+	if err != nil {
+		return res, err
+	}
+	if news.ID == 0 {
+		return res, fmt.Errorf("record not found")
+	}
+
+	from := Status(news.Status)
+	if err = checkTransition(from, to, reason); err != nil {
+		return res, err
+	}
+
+	switch to {
+	case StatusPublish:
+		news.PublishAt = time.Now()
+	case StatusScheduled:
+		if publishAt == nil || !publishAt.After(time.Now()) {
+			return res, fmt.Errorf("scheduled news needs a future publish_at")
+		}
+		news.PublishAt = *publishAt
+	}
+
+	if to == StatusPublish || to == StatusScheduled {
+		if err = checkUnpublishAt(news.PublishAt, news.UnpublishAt); err != nil {
+			return res, err
+		}
+	}
+
+	// the status changes together with its transition
+	err = us.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		txRepo := NewRepository(tx)
+		ok, err := txRepo.UpdateStatus(context, news.ID, from, to, news.PublishAt)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w, news status has changed", ErrInvalidTransition)
+		}
+
+		// record transition
+		_, err = txRepo.AddTransition(context, Transition{
+			NewsID: news.ID,
+			From:   string(from),
+			To:     string(to),
+			Reason: reason,
+			Actor:  editor(context, ""),
+		})
+		return err
+	})
+	if err != nil {
+		return res, err
+	}
+	news.Status = string(to)
+
+	return news, nil
+}
+
+// Restore takes a news out of the trash with the status it had before deletion.
+func (us *useCase) Restore(context context.Context, id int) (res News, err error) {
+	news, err := us.repo.GetTrashedByID(context, id)
+	if err != nil {
+		return res, err
+	}
+
+	// find the status before deletion, fall back to draft
+	status := StatusDraft
+	transitions, err := us.repo.GetTransitions(context, id)
+	if err != nil {
+		return res, err
+	}
+	for _, v := range transitions {
+		if v.To == string(StatusDelete) && v.From != string(StatusDelete) {
+			status = Status(v.From)
+		}
+	}
+
+	// the news leaves the trash together with its transition
+	err = us.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		txRepo := NewRepository(tx)
+		if err := txRepo.Restore(context, news.ID, status); err != nil {
+			return err
+		}
+
+		// record transition
+		_, err := txRepo.AddTransition(context, Transition{
+			NewsID: news.ID,
+			From:   string(StatusDelete),
+			To:     string(status),
+			Actor:  editor(context, ""),
+		})
+		return err
+	})
+	if err != nil {
+		return res, err
+	}
+
+	res, err = us.repo.GetByID(context, id)
+	return res, err
+}
+
+// Purge permanently removes a news, it must be in the trash.
+func (us *useCase) Purge(context context.Context, id int) (err error) {
+	news, err := us.repo.GetTrashedByID(context, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = us.repo.Purge(context, []uint{news.ID})
+	return err
+}
+
+// PurgeTrash permanently removes every news deleted before the given time.
+func (us *useCase) PurgeTrash(context context.Context, before time.Time) (purged int64, err error) {
+	ids, err := us.repo.GetTrashedBefore(context, before)
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err = us.repo.Purge(context, ids)
+	return purged, err
+}
+
+// Bulk runs a list of operations, in transaction mode the first failure rolls
+// every operation back, in best effort mode each operation stands on its own.
+func (us *useCase) Bulk(context context.Context, dto BulkDTO) (res []BulkResult, err error) {
+	if dto.Mode == BulkBestEffort {
+		for _, v := range dto.Operations {
+			res = append(res, newBulkResult(v, us.bulk(context, v)))
+		}
+		return res, nil
+	}
+
+	err = us.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		txUseCase := &useCase{repo: NewRepository(tx)}
+		for _, v := range dto.Operations {
+			if err := txUseCase.bulk(context, v); err != nil {
+				res = append(res, newBulkResult(v, err))
+				return err
+			}
+			res = append(res, newBulkResult(v, nil))
+		}
+		return nil
+	})
 	if err != nil {
+		// nothing was saved, the operations after the failure did not run
+		for i := range res {
+			if res[i].Success {
+				res[i].Success, res[i].Error = false, ErrBulkRolledBack.Error()
+			}
+		}
+		for _, v := range dto.Operations[len(res):] {
+			res = append(res, BulkResult{Op: v.Op, ID: v.ID, Error: "not run"})
+		}
+		return res, fmt.Errorf("%w: %s", ErrBulkRolledBack, err.Error())
+	}
+
+	return res, nil
+}
+
+// bulk runs a single bulk operation through the same rules as the single item endpoints.
+func (us *useCase) bulk(context context.Context, op BulkOperation) (err error) {
+	switch op.Op {
+	case BulkStatus:
+		to := Status(op.Status)
+		if to == StatusPublish && op.PublishAt != nil && op.PublishAt.After(time.Now()) {
+			to = StatusScheduled
+		}
+		_, err = us.Transition(context, int(op.ID), to, op.Reason, op.PublishAt)
+		return err
+	case BulkDelete:
+		err = us.Delete(context, int(op.ID), 0)
 		return err
 	}
 
+	news, err := us.repo.GetByID(context, int(op.ID))
+	if err != nil {
+		return err
+	}
+	if news.ID == 0 {
+		return fmt.Errorf("record not found")
+	}
+
+	dto := newDTO(news)
+	dto.Version = news.Version
+	switch op.Op {
+	case BulkAddTags:
+		for _, v := range op.Tags {
+			if !containsID(dto.Tags, v) {
+				dto.Tags = append(dto.Tags, v)
+			}
+		}
+	case BulkRemoveTags:
+		tags := []uint{}
+		for _, v := range dto.Tags {
+			if !containsID(op.Tags, v) {
+				tags = append(tags, v)
+			}
+		}
+		dto.Tags = tags
+	case BulkTopic:
+		dto.TopicID = op.TopicID
+	}
+
+	_, err = us.Save(context, dto)
+	return err
+}
+
+func (us *useCase) FindTransitions(context context.Context, id int) (res []Transition, err error) {
+	res, err = us.repo.GetTransitions(context, id)
+	return res, err
+}
+
+func (us *useCase) PublishScheduled(context context.Context) (ids []uint, err error) {
+	ids, err = us.repo.PublishScheduled(context, time.Now())
+	if err != nil {
+		return ids, err
+	}
+
+	// record transition
+	for _, id := range ids {
+		_, err = us.repo.AddTransition(context, Transition{
+			NewsID: id,
+			From:   string(StatusScheduled),
+			To:     string(StatusPublish),
+			Actor:  "publisher",
+		})
+		if err != nil {
+			return ids, err
+		}
+	}
+
+	return ids, nil
+}
+
+// UnpublishExpired takes down the published news past their unpublish time.
+func (us *useCase) UnpublishExpired(context context.Context) (ids []uint, err error) {
+	ids, err = us.repo.UnpublishExpired(context, time.Now())
+	if err != nil {
+		return ids, err
+	}
+
+	// record transition
+	for _, id := range ids {
+		_, err = us.repo.AddTransition(context, Transition{
+			NewsID: id,
+			From:   string(StatusPublish),
+			To:     string(StatusExpired),
+			Reason: "unpublish_at reached",
+			Actor:  "publisher",
+		})
+		if err != nil {
+			return ids, err
+		}
+	}
+
+	return ids, nil
+}
+
+func (us *useCase) FindRevisions(context context.Context, id int) (res []Revision, err error) {
+	res, err = us.repo.GetRevisions(context, id)
+	return res, err
+}
+
+func (us *useCase) DiffRevisions(context context.Context, id int, from int, to int) (res RevisionDiff, err error) {
+	a, err := us.repo.GetRevision(context, id, from)
+	if err != nil {
+		return res, err
+	}
+
+	b, err := us.repo.GetRevision(context, id, to)
+	if err != nil {
+		return res, err
+	}
+
+	res = RevisionDiff{
+		From:    a.ID,
+		To:      b.ID,
+		Title:   tools.DiffWords(a.Title, b.Title),
+		Content: tools.DiffWords(a.Content, b.Content),
+	}
+	return res, nil
+}
+
+// RestoreRevision saves the title, content, tags and topic of an old revision
+// as a new revision, the current status and writer of the news are kept.
+func (us *useCase) RestoreRevision(context context.Context, id int, revisionID int) (res News, err error) {
+	revision, err := us.repo.GetRevision(context, id, revisionID)
+	if err != nil {
+		return res, err
+	}
+
+	news, err := us.repo.GetByID(context, id)
+	if err != nil {
+		return res, err
+	}
+	if news.ID == 0 {
+		return res, fmt.Errorf("record not found")
+	}
+
+	dto := NewsDTO{
+		ID:       news.ID,
+		Title:    revision.Title,
+		Writer:   news.Writer,
+		WriterID: news.WriterID,
+		Content:  revision.Content,
+		Status:   news.Status,
+		Tags:     revision.TagIDs,
+		TopicID:  revision.TopicID,
+	}
+	if news.Status == string(StatusScheduled) {
+		dto.PublishAt = &news.PublishAt
+	}
+
+	res, err = us.Save(context, dto)
+	return res, err
+}
+
+// FindBySlug looks a news up by its slug, when the slug is an old alias
+// the news is not returned, only its canonical slug.
+func (us *useCase) FindBySlug(context context.Context, slug string) (res News, canonical string, err error) {
+	res, err = us.repo.GetBySlug(context, slug)
+	if err == nil {
+		list := []News{res}
+		err = us.translate(context, list)
+		return list[0], res.Slug, err
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return res, "", err
+	}
+
+	alias, err := us.repo.GetSlugAlias(context, slug)
+	if err != nil {
+		return res, "", err
+	}
+
+	news, err := us.repo.GetByID(context, int(alias.NewsID))
+	if err != nil {
+		return res, "", err
+	}
+	if news.ID == 0 {
+		return res, "", gorm.ErrRecordNotFound
+	}
+
+	return News{}, news.Slug, nil
+}
+
+// FindRelated lists the published news closest to a news by tags, topic and recency.
+func (us *useCase) FindRelated(context context.Context, id int, limit int) (res []News, err error) {
+	news, err := us.repo.GetByID(context, id)
+	if err != nil {
+		return res, err
+	}
+	if news.ID == 0 {
+		return res, gorm.ErrRecordNotFound
+	}
+	if news.TopicID == 0 && len(news.Tags) == 0 {
+		return []News{}, nil
+	}
+
+	res, err = us.repo.GetRelated(context, news, limit)
+	return res, err
+}
+
+// MigrateWriters links news saved with a free text writer to writer records.
+func (us *useCase) MigrateWriters(context context.Context) (err error) {
+	err = us.repo.MigrateWriters(context)
+	return err
+}
+
+// BackfillSlugs generates a slug for every news saved before slugs existed.
+func (us *useCase) BackfillSlugs(context context.Context) (err error) {
+	news, err := us.repo.GetWithoutSlug(context)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range news {
+		slug := tools.Slugify(v.Title)
+		if slug == "" {
+			slug = "news"
+		}
+
+		slug, err = us.uniqueSlug(context, slug, v.ID)
+		if err != nil {
+			return err
+		}
+
+		if err = us.repo.SetSlug(context, v.ID, slug); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+// BackfillContent renders and summarizes the news and translations saved
+// before rendered contents and summaries were stored.
+func (us *useCase) BackfillContent(context context.Context) (err error) {
+	news, err := us.repo.GetWithoutSummary(context)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range news {
+		if v.ContentHTML == "" {
+			v.ContentHTML, err = tools.RenderContent(v.ContentFormat, v.Content)
+			if err != nil {
+				return err
+			}
+		}
+
+		excerpt, words, minutes := summarize(v.ContentHTML)
+		if err = us.repo.SetSummary(context, v.ID, v.ContentHTML, excerpt, words, minutes); err != nil {
+			return err
+		}
+	}
+
+	translations, err := us.repo.GetTranslationsWithoutSummary(context)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range translations {
+		excerpt, words, minutes := summarize(v.ContentHTML)
+		if err = us.repo.SetTranslationSummary(context, v.ID, excerpt, words, minutes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IndexFingerprints creates the indexes looking news up by the fingerprint
+// bands of the configured duplicate threshold.
+func (us *useCase) IndexFingerprints(context context.Context) (err error) {
+	err = us.repo.CreateBandIndexes(context, duplicateBands(DuplicateThreshold))
+	return err
+}
+
+// BackfillFingerprints fingerprints the news saved before duplicate detection existed.
+func (us *useCase) BackfillFingerprints(context context.Context) (err error) {
+	news, err := us.repo.GetWithoutFingerprint(context)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range news {
+		if err = us.repo.SetFingerprint(context, v.ID, int64(tools.SimHash(tools.PlainText(v.ContentHTML)))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FindDuplicates groups every news with the ones at least threshold similar to it,
+// a news similar to any news of a group joins the group.
+func (us *useCase) FindDuplicates(context context.Context, threshold float64) (res []DuplicateCluster, err error) {
+	news, err := us.repo.GetFingerprints(context, time.Time{})
+	if err != nil {
+		return res, err
+	}
+
+	// union find over the similar pairs
+	parent := make([]int, len(news))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	// similar fingerprints share a band, only news in the same bucket of a band are compared
+	bands := duplicateBands(threshold)
+	buckets := make([]map[uint64][]int, bands)
+	for b := range buckets {
+		buckets[b] = map[uint64][]int{}
+	}
+
+	for i := range news {
+		for b, key := range tools.Bands(uint64(news[i].Fingerprint), bands) {
+			for _, j := range buckets[b][key] {
+				if find(i) != find(j) && tools.Similarity(uint64(news[i].Fingerprint), uint64(news[j].Fingerprint)) >= threshold {
+					parent[find(i)] = find(j)
+				}
+			}
+			buckets[b][key] = append(buckets[b][key], i)
+		}
+	}
+
+	groups := map[int][]int{}
+	var roots []int
+	for i := range news {
+		root := find(i)
+		if groups[root] == nil {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], i)
+	}
+
+	for _, root := range roots {
+		group := groups[root]
+		if len(group) < 2 {
+			continue
+		}
+
+		cluster := DuplicateCluster{Similarity: 1}
+		for k, i := range group {
+			cluster.IDs = append(cluster.IDs, news[i].ID)
+			for _, j := range group[k+1:] {
+				if v := tools.Similarity(uint64(news[i].Fingerprint), uint64(news[j].Fingerprint)); v < cluster.Similarity {
+					cluster.Similarity = v
+				}
+			}
+		}
+		res = append(res, cluster)
+	}
+
+	return res, nil
+}
+
+// findDuplicates lists the recent news similar to a fingerprint, except the news itself.
+func (us *useCase) findDuplicates(context context.Context, fingerprint int64, newsID uint) (ids []uint, err error) {
+	if fingerprint == 0 {
+		return nil, nil
+	}
+
+	// only the news sharing a band with the fingerprint can be similar
+	news, err := us.repo.GetFingerprintsByBands(context, time.Now().Add(-DuplicateWindow), fingerprint, duplicateBands(DuplicateThreshold))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range news {
+		if v.ID != newsID && tools.Similarity(uint64(fingerprint), uint64(v.Fingerprint)) >= DuplicateThreshold {
+			ids = append(ids, v.ID)
+		}
+	}
+
+	return ids, nil
+}
+
+// uniqueSlug appends a numeric suffix until the slug is not taken by another news.
+func (us *useCase) uniqueSlug(context context.Context, base string, newsID uint) (string, error) {
+	slug := base
+	for i := 2; ; i++ {
+		exists, err := us.repo.SlugExists(context, slug, newsID)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+func (us *useCase) FindTranslations(context context.Context, id int) (res []Translation, err error) {
+	res, err = us.repo.GetTranslations(context, []uint{uint(id)}, nil)
+	return res, err
+}
+
+// SaveTranslation adds or replaces the translation of a news in a locale,
+// the content is rendered the same way as the news content.
+func (us *useCase) SaveTranslation(context context.Context, id int, lang string, dto TranslationDTO) (res Translation, err error) {
+	lang = locale.Normalize(lang)
+	if lang == "" {
+		return res, fmt.Errorf("invalid locale")
+	}
+
+	if strings.TrimSpace(dto.Title) == "" || strings.TrimSpace(dto.Content) == "" {
+		return res, fmt.Errorf("invalid parameters")
+	}
+
+	news, err := us.repo.GetByID(context, id)
+	if err != nil {
+		return res, err
+	}
+	if news.ID == 0 {
+		return res, fmt.Errorf("record not found")
+	}
+	if news.Locale == lang {
+		return res, fmt.Errorf("news %d is written in %s, update the news instead", news.ID, lang)
+	}
+
+	if dto.ContentFormat == "" {
+		dto.ContentFormat = news.ContentFormat
+	}
+	if dto.ContentFormat == "" {
+		dto.ContentFormat = tools.FormatPlain
+	}
+	contentHTML, err := tools.RenderContent(dto.ContentFormat, dto.Content)
+	if err != nil {
+		return res, err
+	}
+	if dto.ContentFormat == tools.FormatHTML {
+		dto.Content = contentHTML
+	}
+
+	excerpt, words, minutes := summarize(contentHTML)
+	res, err = us.repo.UpsertTranslation(context, Translation{
+		NewsID:        news.ID,
+		Locale:        lang,
+		Title:         dto.Title,
+		Content:       dto.Content,
+		ContentFormat: dto.ContentFormat,
+		ContentHTML:   contentHTML,
+		Excerpt:       excerpt,
+		WordCount:     words,
+		ReadingTime:   minutes,
+		UpdatedAt:     time.Now(),
+	})
+	return res, err
+}
+
+func (us *useCase) DeleteTranslation(context context.Context, id int, lang string) (err error) {
+	ok, err := us.repo.DeleteTranslation(context, id, locale.Normalize(lang))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// translate serves every news in the first locale of the chain carried by the
+// context it is written or translated in, without a chain news are left as is.
+func (us *useCase) translate(context context.Context, news []News) error {
+	chain, _ := context.Value(ContextKey("locales")).([]string)
+	if len(chain) == 0 || len(news) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(news))
+	for _, v := range news {
+		ids = append(ids, v.ID)
+	}
+	translations, err := us.repo.GetTranslations(context, ids, chain)
+	if err != nil {
+		return err
+	}
+
+	byLocale := map[uint]map[string]Translation{}
+	for _, v := range translations {
+		if byLocale[v.NewsID] == nil {
+			byLocale[v.NewsID] = map[string]Translation{}
+		}
+		byLocale[v.NewsID][v.Locale] = v
+	}
+
+	for i := range news {
+		for _, lang := range chain {
+			if lang == news[i].Locale {
+				break
+			}
+			if t, ok := byLocale[news[i].ID][lang]; ok {
+				news[i].translate(t)
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// newDTO returns the editable fields of a news.
+func newDTO(news News) NewsDTO {
+	dto := NewsDTO{
+		ID:            news.ID,
+		Title:         news.Title,
+		Slug:          news.Slug,
+		WriterID:      news.WriterID,
+		Content:       news.Content,
+		Status:        news.Status,
+		Tags:          []uint{},
+		TopicID:       news.TopicID,
+		UnpublishAt:   news.UnpublishAt,
+		ContentFormat: news.ContentFormat,
+		Locale:        news.Locale,
+	}
+
+	for _, v := range news.Tags {
+		dto.Tags = append(dto.Tags, v.ID)
+	}
+
+	for _, v := range news.Media {
+		dto.Media = append(dto.Media, v.ID)
+	}
+
+	if news.CoverID != nil {
+		dto.CoverID = *news.CoverID
+	}
+
+	if news.Status == string(StatusScheduled) {
+		dto.PublishAt = &news.PublishAt
+	}
+
+	return dto
+}
+
+func newBulkResult(op BulkOperation, err error) BulkResult {
+	res := BulkResult{Op: op.Op, ID: op.ID, Success: err == nil}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
+
+func containsMedia(list []media.Media, id uint) bool {
+	for _, v := range list {
+		if v.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func containsTag(list []tag.Tag, id uint) bool {
+	for _, v := range list {
+		if v.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// containsName reports whether a tag name is in the list regardless of case and spacing.
+func containsName(names []string, name string) bool {
+	for _, v := range names {
+		if tag.TagKey(v) == tag.TagKey(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func newRevision(news News, author string) Revision {
+	tagIDs := UintList{}
+	for _, v := range news.Tags {
+		tagIDs = append(tagIDs, v.ID)
+	}
+
+	return Revision{
+		NewsID:  news.ID,
+		Title:   news.Title,
+		Content: news.Content,
+		Status:  news.Status,
+		TagIDs:  tagIDs,
+		TopicID: news.Topic.ID,
+		Author:  author,
+	}
+}
+
+// editor returns the user making the change, it falls back to the writer
+// when the request does not carry one.
+func editor(context context.Context, fallback string) string {
+	if v, ok := context.Value(ContextKey("editor")).(string); ok && v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,297 @@
+package section
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ntm/internal/infrastructure/cache"
+	"github.com/ntm/internal/pkg/common/http/response"
+	"github.com/ntm/internal/pkg/common/pagination"
+	"github.com/ntm/internal/pkg/common/sorting"
+	"github.com/ntm/internal/tools"
+	"gorm.io/gorm"
+)
+
+// sectionPage is the cached form of a paginated listing.
+type sectionPage struct {
+	Data []Section       `json:"data"`
+	Meta pagination.Meta `json:"meta"`
+}
+
+type HTTPController struct {
+	sectionUseCase UseCase
+	cacher         cache.Cacher
+}
+
+func NewHTTPController(sectionUseCase UseCase, cacher cache.Cacher) *HTTPController {
+	return &HTTPController{
+		sectionUseCase: sectionUseCase,
+		cacher:         cacher,
+	}
+}
+
+func (controller *HTTPController) FindAll(c *gin.Context) {
+	var filter Filter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	if err := filter.Normalize(); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	// validate sort order
+	sort, err := sorting.Parse(filter.Sort, SortFields)
+	if err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid sort parameter", err)
+		return
+	}
+	if len(sort) > 0 && filter.Cursor != "" {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid sort parameter", fmt.Errorf("cursor pagination only supports the default sort order"))
+		return
+	}
+
+	// get from cache
+	cache_key := tools.MD5([]byte(fmt.Sprintf("sections:%s:%s", sort, c.Request.URL.Query().Encode())))
+	if controller.cacher.IsExist(cache_key) {
+		log.Println("section | findAll | serve by redis")
+		payload := sectionPage{}
+		if err := json.Unmarshal([]byte(controller.cacher.Get(cache_key).(string)), &payload); err != nil {
+			response.Error(c, http.StatusInternalServerError, err)
+			return
+		}
+		response.SuccessWithMeta(c, http.StatusOK, payload.Data, payload.Meta)
+		return
+	}
+
+	// create context
+	ctx := context.WithValue(context.Background(), ContextKey("sections_filter"), filter)
+
+	// get from db
+	sections, total, err := controller.sectionUseCase.FindAll(ctx)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// build pagination meta
+	var firstID, lastID uint
+	if len(sections) > 0 {
+		firstID, lastID = sections[0].ID, sections[len(sections)-1].ID
+	}
+	page := sectionPage{
+		Data: sections,
+		Meta: pagination.NewMeta(c.Request.URL, filter.Pagination, total, len(sections), firstID, lastID),
+	}
+
+	// cursors are keyed on id and only valid for the default sort order
+	if len(sort) > 0 {
+		page.Meta.NextCursor, page.Meta.PrevCursor = "", ""
+	}
+
+	// save in cache
+	cache_val, _ := json.Marshal(page)
+	if err := controller.cacher.Put(cache_key, cache_val, 600); err != nil {
+		log.Println(err.Error())
+	}
+
+	response.SuccessWithMeta(c, http.StatusOK, page.Data, page.Meta)
+}
+
+func (controller *HTTPController) FindByID(c *gin.Context) {
+	id := c.Param("id")
+
+	// get from cache
+	cache_key := tools.MD5([]byte(fmt.Sprintf("section_id:" + id)))
+	if controller.cacher.IsExist(cache_key) {
+		log.Println("section | findByID | serve by redis")
+		payload := Section{}
+		if err := json.Unmarshal([]byte(controller.cacher.Get(cache_key).(string)), &payload); err != nil {
+			response.Error(c, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(c, http.StatusOK, payload)
+		return
+	}
+
+	// get from db
+	section, err := controller.sectionUseCase.FindByID(c.Request.Context(), tools.StringsToInt(id))
+	if err != nil {
+		failed(c, err)
+		return
+	}
+
+	// save in cache
+	cache_val, _ := json.Marshal(section)
+	if err := controller.cacher.Put(cache_key, cache_val, 600); err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, section)
+}
+
+func (controller *HTTPController) Add(c *gin.Context) {
+	var err error
+	var section Section
+
+	err = c.Bind(&section)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	_, err = controller.sectionUseCase.Add(c.Request.Context(), section)
+	if err != nil {
+		failed(c, err)
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, nil)
+}
+
+func (controller *HTTPController) Update(c *gin.Context) {
+	var err error
+	var section Section
+
+	err = c.Bind(&section)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	_, err = controller.sectionUseCase.Update(c.Request.Context(), section, tools.StringsToInt(c.Param("id")))
+	if err != nil {
+		failed(c, err)
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, nil)
+}
+
+func (controller *HTTPController) Delete(c *gin.Context) {
+	var err error
+
+	id := c.Param("id")
+	err = controller.sectionUseCase.Delete(c.Request.Context(), tools.StringsToInt(id))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, nil)
+}
+
+// Render serves the live layout of a section by its key.
+func (controller *HTTPController) Render(c *gin.Context) {
+	key := c.Param("key")
+
+	// get from cache, a short ttl lets expired slots and new news in
+	cache_key := tools.MD5([]byte(fmt.Sprintf("section_key:" + key)))
+	if controller.cacher.IsExist(cache_key) {
+		log.Println("section | render | serve by redis")
+		payload := Layout{}
+		if err := json.Unmarshal([]byte(controller.cacher.Get(cache_key).(string)), &payload); err != nil {
+			response.Error(c, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(c, http.StatusOK, payload)
+		return
+	}
+
+	// get from db
+	layout, err := controller.sectionUseCase.Render(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(c, http.StatusNotFound, fmt.Errorf("record not found"))
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// save in cache
+	cache_val, _ := json.Marshal(layout)
+	if err := controller.cacher.Put(cache_key, cache_val, 60); err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, layout)
+}
+
+// SetSlots replaces the draft layout, the slots are positioned in the order sent.
+func (controller *HTTPController) SetSlots(c *gin.Context) {
+	var slots []Slot
+	if err := c.ShouldBindJSON(&slots); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	section, err := controller.sectionUseCase.SetSlots(c.Request.Context(), tools.StringsToInt(c.Param("id")), slots)
+	if err != nil {
+		failed(c, err)
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, section)
+}
+
+// Publish makes the draft layout of a section live.
+func (controller *HTTPController) Publish(c *gin.Context) {
+	section, err := controller.sectionUseCase.Publish(c.Request.Context(), tools.StringsToInt(c.Param("id")))
+	if err != nil {
+		failed(c, err)
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, section)
+}
+
+// failed answers a use case error, invalid sections and layouts with 400
+// and unknown sections with 404.
+func failed(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvalid):
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		response.Error(c, http.StatusNotFound, fmt.Errorf("record not found"))
+	default:
+		response.Error(c, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,70 @@
+package section
+
+import (
+	"time"
+
+	"github.com/ntm/internal/domain/news"
+	"github.com/ntm/internal/pkg/common/pagination"
+)
+
+// Section is a curated list of news, like the homepage or the top of a topic.
+type Section struct {
+	ID          uint       `gorm:"primaryKey" json:"id,omitempty"`
+	Key         string     `gorm:"not null;type:varchar(100);uniqueIndex" json:"key,omitempty"`
+	Name        string     `gorm:"not null;type:varchar(100)" json:"name,omitempty"`
+	TopicID     uint       `gorm:"default:null;index" json:"topic_id,omitempty"`
+	Size        int        `gorm:"not null" json:"size,omitempty"`
+	Slots       []Slot     `gorm:"foreignKey:SectionID" json:"slots,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"default:current_timestamp;index" json:"created_at,omitempty"`
+	UpdatedAt   time.Time  `gorm:"default:current_timestamp" json:"updated_at,omitempty"`
+}
+
+// Slot pins a news at a position of a section, editors arrange the draft
+// slots and publishing the section copies them to the live layout.
+type Slot struct {
+	ID        uint       `gorm:"primaryKey" json:"id,omitempty"`
+	SectionID uint       `gorm:"not null;index" json:"-"`
+	Live      bool       `gorm:"not null" json:"live"`
+	Position  int        `gorm:"not null" json:"position"`
+	NewsID    uint       `gorm:"not null" json:"news_id"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Item is a news placed in a rendered section, pinned items come from a slot
+// and the others fill the empty positions with the latest published news.
+type Item struct {
+	Position int       `json:"position"`
+	Pinned   bool      `json:"pinned"`
+	News     news.News `json:"news"`
+}
+
+type Layout struct {
+	Section Section `json:"section"`
+	Items   []Item  `json:"items"`
+}
+
+const (
+	DefaultSize = 5
+	MaxSize     = 50
+)
+
+type Filter struct {
+	Key          string `form:"key"`
+	Topic        uint   `form:"topic"`
+	CreatedStart string `form:"created_start"`
+	CreatedEnd   string `form:"created_end"`
+	Sort         string `form:"sort"`
+	pagination.Pagination
+}
+
+// SortFields lists the fields a listing can be sorted by.
+var SortFields = map[string]string{
+	"id":         "id",
+	"key":        "key",
+	"name":       "name",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+type ContextKey string
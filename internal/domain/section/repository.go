@@ -0,0 +1,142 @@
+package section
+
+import (
+	"context"
+	"time"
+
+	"github.com/ntm/internal/pkg/common/pagination"
+	"github.com/ntm/internal/pkg/common/sorting"
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	GetAll(ctx context.Context) ([]Section, error)
+	Count(ctx context.Context) (int64, error)
+	GetByID(ctx context.Context, id int) (Section, error)
+	GetByKey(ctx context.Context, key string) (Section, error)
+	Upsert(ctx context.Context, model Section) (Section, error)
+	ReplaceSlots(ctx context.Context, id uint, slots []Slot) error
+	Publish(ctx context.Context, id uint, now time.Time) error
+	DeleteByID(ctx context.Context, id int) error
+	GetDB() *gorm.DB
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db}
+}
+
+func (r *repository) GetAll(ctx context.Context) (res []Section, err error) {
+	filter := ctx.Value(ContextKey("sections_filter")).(Filter)
+	exec := r.filter(r.db, filter)
+
+	result := exec.Scopes(sorting.Scope(filter.Sort, SortFields), pagination.Scope(filter.Pagination, "id")).Find(&res)
+	if result.Error != nil {
+		return res, result.Error
+	}
+
+	if filter.IsBackward() {
+		for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+			res[i], res[j] = res[j], res[i]
+		}
+	}
+
+	return res, nil
+}
+
+func (r *repository) Count(ctx context.Context) (total int64, err error) {
+	filter := ctx.Value(ContextKey("sections_filter")).(Filter)
+	result := r.filter(r.db.Model(&Section{}), filter).Count(&total)
+	return total, result.Error
+}
+
+// filter applies the listing conditions shared by GetAll and Count.
+func (r *repository) filter(exec *gorm.DB, filter Filter) *gorm.DB {
+	var dateValid bool
+	_, a := time.Parse("2006-01-02", filter.CreatedStart)
+	_, b := time.Parse("2006-01-02", filter.CreatedEnd)
+	if a == nil && b == nil {
+		dateValid = true
+	}
+
+	if filter.Key != "" {
+		exec = exec.Where("key = ?", filter.Key)
+	}
+
+	if filter.Topic != 0 {
+		exec = exec.Where("topic_id = ?", filter.Topic)
+	}
+
+	if dateValid {
+		exec = exec.Where("to_char(created_at, 'YYYY-MM-DD') between ? and ?", filter.CreatedStart, filter.CreatedEnd)
+	}
+
+	return exec
+}
+
+// GetByID returns a section with its draft and live slots.
+func (r *repository) GetByID(ctx context.Context, id int) (res Section, err error) {
+	result := r.db.Preload("Slots", func(db *gorm.DB) *gorm.DB {
+		return db.Order("live, position")
+	}).First(&res, id)
+	return res, result.Error
+}
+
+// GetByKey returns a section with its live slots only.
+func (r *repository) GetByKey(ctx context.Context, key string) (res Section, err error) {
+	result := r.db.Preload("Slots", func(db *gorm.DB) *gorm.DB {
+		return db.Where("live = ?", true).Order("position")
+	}).Where("key = ?", key).First(&res)
+	return res, result.Error
+}
+
+func (r *repository) Upsert(ctx context.Context, model Section) (res Section, err error) {
+	result := r.db.Omit("Slots").Save(&model)
+	return model, result.Error
+}
+
+// ReplaceSlots swaps the draft slots of a section, the live ones are left alone.
+func (r *repository) ReplaceSlots(ctx context.Context, id uint, slots []Slot) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("section_id = ? and live = ?", id, false).Delete(&Slot{}).Error; err != nil {
+			return err
+		}
+		if len(slots) == 0 {
+			return nil
+		}
+		return tx.Create(&slots).Error
+	})
+}
+
+// Publish copies the draft slots of a section over its live slots.
+func (r *repository) Publish(ctx context.Context, id uint, now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("section_id = ? and live = ?", id, true).Delete(&Slot{}).Error; err != nil {
+			return err
+		}
+
+		err := tx.Exec("INSERT INTO slots (section_id, live, position, news_id, expires_at) "+
+			"SELECT section_id, true, position, news_id, expires_at FROM slots WHERE section_id = ? AND live = false", id).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&Section{}).Where("id = ?", id).Updates(map[string]interface{}{"published_at": now, "updated_at": now}).Error
+	})
+}
+
+func (r *repository) DeleteByID(ctx context.Context, id int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("section_id = ?", id).Delete(&Slot{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Section{ID: uint(id)}).Error
+	})
+}
+
+func (r *repository) GetDB() *gorm.DB {
+	return r.db
+}
@@ -0,0 +1,251 @@
+package section
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ntm/internal/domain/news"
+	"github.com/ntm/internal/pkg/common/pagination"
+	"github.com/ntm/internal/tools"
+)
+
+// ErrInvalid marks the errors caused by an invalid section or layout.
+var ErrInvalid = errors.New("invalid section")
+
+type UseCase interface {
+	FindAll(context context.Context) ([]Section, int64, error)
+	FindByID(context context.Context, id int) (Section, error)
+	Render(context context.Context, key string) (Layout, error)
+	Add(context context.Context, model Section) (Section, error)
+	Update(context context.Context, model Section, id int) (Section, error)
+	SetSlots(context context.Context, id int, slots []Slot) (Section, error)
+	Publish(context context.Context, id int) (Section, error)
+	Delete(context context.Context, id int) error
+}
+
+type useCase struct {
+	repo Repository
+}
+
+func NewUseCase(repo Repository) UseCase {
+	return &useCase{
+		repo: repo,
+	}
+}
+
+func (us *useCase) FindAll(context context.Context) (res []Section, total int64, err error) {
+	res, err = us.repo.GetAll(context)
+	if err != nil {
+		return res, total, err
+	}
+
+	total, err = us.repo.Count(context)
+	return res, total, err
+}
+
+func (us *useCase) FindByID(context context.Context, id int) (res Section, err error) {
+	res, err = us.repo.GetByID(context, id)
+	return res, err
+}
+
+// Render builds the live layout of a section, pinned news keep their position
+// and the remaining positions are filled with the latest published news.
+func (us *useCase) Render(context context.Context, key string) (res Layout, err error) {
+	section, err := us.repo.GetByKey(context, key)
+	if err != nil {
+		return res, err
+	}
+
+	newsRepo := news.NewRepository(us.repo.GetDB())
+	now := time.Now()
+	positions := make([]*Item, section.Size)
+	placed := map[uint]bool{}
+
+	for _, v := range section.Slots {
+		if v.Position < 1 || v.Position > section.Size || positions[v.Position-1] != nil {
+			continue
+		}
+		if v.ExpiresAt != nil && !v.ExpiresAt.After(now) {
+			continue
+		}
+
+		// unpublished or deleted news leave their position to the automatic fill
+		item, err := newsRepo.GetByID(context, int(v.NewsID))
+		if err != nil {
+			return res, err
+		}
+		if item.ID == 0 || item.Status != string(news.StatusPublish) || placed[item.ID] {
+			continue
+		}
+
+		positions[v.Position-1] = &Item{Position: v.Position, Pinned: true, News: item}
+		placed[item.ID] = true
+	}
+
+	// fill the empty positions, enough news are fetched to skip the pinned ones
+	if len(placed) < section.Size {
+		filter := news.Filter{
+			Status:     string(news.StatusPublish),
+			Topic:      section.TopicID,
+			Sort:       "-publish_at",
+			Pagination: pagination.Pagination{Page: 1, Limit: section.Size + len(placed)},
+		}
+		latest, err := newsRepo.GetAll(newsContext(context, filter))
+		if err != nil {
+			return res, err
+		}
+
+		i := 0
+		for _, v := range latest {
+			if placed[v.ID] {
+				continue
+			}
+			for i < len(positions) && positions[i] != nil {
+				i++
+			}
+			if i == len(positions) {
+				break
+			}
+			positions[i] = &Item{Position: i + 1, News: v}
+			placed[v.ID] = true
+		}
+	}
+
+	res.Items = []Item{}
+	for _, v := range positions {
+		if v != nil {
+			res.Items = append(res.Items, *v)
+		}
+	}
+
+	section.Slots = nil
+	res.Section = section
+	return res, nil
+}
+
+func (us *useCase) Add(context context.Context, model Section) (res Section, err error) {
+	if err = validate(&model); err != nil {
+		return res, err
+	}
+
+	model.ID = 0
+	model.Slots = nil
+	model.PublishedAt = nil
+	res, err = us.repo.Upsert(context, model)
+	return res, err
+}
+
+func (us *useCase) Update(context context.Context, model Section, id int) (res Section, err error) {
+	if err = validate(&model); err != nil {
+		return res, err
+	}
+
+	if id == 0 {
+		return res, fmt.Errorf("%w: id is required", ErrInvalid)
+	}
+
+	// get id first
+	res, err = us.repo.GetByID(context, id)
+	if err != nil {
+		return res, err
+	}
+
+	// update section, slots are changed through SetSlots
+	res.Key = model.Key
+	res.Name = model.Name
+	res.TopicID = model.TopicID
+	res.Size = model.Size
+	res, err = us.repo.Upsert(context, res)
+
+	return res, err
+}
+
+// SetSlots replaces the draft layout of a section, slots are positioned in the order given.
+func (us *useCase) SetSlots(context context.Context, id int, slots []Slot) (res Section, err error) {
+	res, err = us.repo.GetByID(context, id)
+	if err != nil {
+		return res, err
+	}
+
+	if len(slots) > res.Size {
+		return res, fmt.Errorf("%w: section %s only has %d slots", ErrInvalid, res.Key, res.Size)
+	}
+
+	newsRepo := news.NewRepository(us.repo.GetDB())
+	now := time.Now()
+	seen := map[uint]bool{}
+	for i := range slots {
+		if seen[slots[i].NewsID] {
+			return res, fmt.Errorf("%w: news %d is placed twice", ErrInvalid, slots[i].NewsID)
+		}
+		if slots[i].ExpiresAt != nil && !slots[i].ExpiresAt.After(now) {
+			return res, fmt.Errorf("%w: the slot of news %d has already expired", ErrInvalid, slots[i].NewsID)
+		}
+		seen[slots[i].NewsID] = true
+
+		item, err := newsRepo.GetByID(context, int(slots[i].NewsID))
+		if err != nil {
+			return res, err
+		}
+		if item.ID == 0 {
+			return res, fmt.Errorf("%w: news %d not found", ErrInvalid, slots[i].NewsID)
+		}
+
+		slots[i].ID = 0
+		slots[i].SectionID = res.ID
+		slots[i].Live = false
+		slots[i].Position = i + 1
+	}
+
+	if err = us.repo.ReplaceSlots(context, res.ID, slots); err != nil {
+		return res, err
+	}
+
+	res, err = us.repo.GetByID(context, id)
+	return res, err
+}
+
+// Publish makes the draft layout of a section live.
+func (us *useCase) Publish(context context.Context, id int) (res Section, err error) {
+	res, err = us.repo.GetByID(context, id)
+	if err != nil {
+		return res, err
+	}
+
+	if err = us.repo.Publish(context, res.ID, time.Now()); err != nil {
+		return res, err
+	}
+
+	res, err = us.repo.GetByID(context, id)
+	return res, err
+}
+
+func (us *useCase) Delete(context context.Context, id int) (err error) {
+	err = us.repo.DeleteByID(context, id)
+	return err
+}
+
+// validate normalizes the key and the size of a section.
+func validate(model *Section) error {
+	model.Key = tools.Slugify(model.Key)
+	model.Name = strings.TrimSpace(model.Name)
+	if model.Key == "" || model.Name == "" {
+		return fmt.Errorf("%w: key and name are required", ErrInvalid)
+	}
+
+	if model.Size == 0 {
+		model.Size = DefaultSize
+	}
+	if model.Size < 1 || model.Size > MaxSize {
+		return fmt.Errorf("%w: size must be between 1 and %d", ErrInvalid, MaxSize)
+	}
+
+	return nil
+}
+
+func newsContext(ctx context.Context, filter news.Filter) context.Context {
+	return context.WithValue(ctx, news.ContextKey("news_filter"), filter)
+}
@@ -3,16 +3,34 @@ package tag
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ntm/internal/infrastructure/cache"
+	"github.com/ntm/internal/pkg/common/http/etag"
 	"github.com/ntm/internal/pkg/common/http/response"
+	"github.com/ntm/internal/pkg/common/pagination"
+	"github.com/ntm/internal/pkg/common/patch"
+	"github.com/ntm/internal/pkg/common/sorting"
 	"github.com/ntm/internal/tools"
+	"gorm.io/gorm"
 )
 
+// tagPage is the cached form of a paginated listing.
+type tagPage struct {
+	Data []Tag           `json:"data"`
+	Meta pagination.Meta `json:"meta"`
+}
+
+// statsPage is the cached form of the stats listing.
+type statsPage struct {
+	Data []Stats         `json:"data"`
+	Meta pagination.Meta `json:"meta"`
+}
+
 type HTTPController struct {
 	tagUseCase UseCase
 	cacher     cache.Cacher
@@ -32,16 +50,32 @@ func (controller *HTTPController) FindAll(c *gin.Context) {
 		return
 	}
 
+	if err := filter.Normalize(); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	// validate sort order
+	sort, err := sorting.Parse(filter.Sort, SortFields)
+	if err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid sort parameter", err)
+		return
+	}
+	if len(sort) > 0 && filter.Cursor != "" {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid sort parameter", fmt.Errorf("cursor pagination only supports the default sort order"))
+		return
+	}
+
 	// get from cache
-	cache_key := tools.MD5([]byte(fmt.Sprintf("tags:%s", c.Request.URL.Query().Encode())))
+	cache_key := tools.MD5([]byte(fmt.Sprintf("tags:%s:%s", sort, c.Request.URL.Query().Encode())))
 	if controller.cacher.IsExist(cache_key) {
 		log.Println("tag | findAll | serve by redis")
-		payload := []Tag{}
+		payload := tagPage{}
 		if err := json.Unmarshal([]byte(controller.cacher.Get(cache_key).(string)), &payload); err != nil {
 			response.Error(c, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(c, http.StatusOK, payload)
+		response.SuccessWithMeta(c, http.StatusOK, payload.Data, payload.Meta)
 		return
 	}
 
@@ -49,19 +83,34 @@ func (controller *HTTPController) FindAll(c *gin.Context) {
 	ctx := context.WithValue(context.Background(), ContextKey("tags_filter"), filter)
 
 	// get from db
-	tags, err := controller.tagUseCase.FindAll(ctx)
+	tags, total, err := controller.tagUseCase.FindAll(ctx)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, err)
 		return
 	}
 
+	// build pagination meta
+	var firstID, lastID uint
+	if len(tags) > 0 {
+		firstID, lastID = tags[0].ID, tags[len(tags)-1].ID
+	}
+	page := tagPage{
+		Data: tags,
+		Meta: pagination.NewMeta(c.Request.URL, filter.Pagination, total, len(tags), firstID, lastID),
+	}
+
+	// cursors are keyed on id and only valid for the default sort order
+	if len(sort) > 0 {
+		page.Meta.NextCursor, page.Meta.PrevCursor = "", ""
+	}
+
 	// save in cache
-	cache_val, _ := json.Marshal(tags)
+	cache_val, _ := json.Marshal(page)
 	if err := controller.cacher.Put(cache_key, cache_val, 600); err != nil {
 		log.Println(err.Error())
 	}
 
-	response.Success(c, http.StatusOK, tags)
+	response.SuccessWithMeta(c, http.StatusOK, page.Data, page.Meta)
 }
 
 func (controller *HTTPController) FindByID(c *gin.Context) {
@@ -76,6 +125,7 @@ func (controller *HTTPController) FindByID(c *gin.Context) {
 			response.Error(c, http.StatusInternalServerError, err)
 			return
 		}
+		c.Header("ETag", etag.Format(payload.Version))
 		response.Success(c, http.StatusOK, payload)
 		return
 	}
@@ -93,6 +143,7 @@ func (controller *HTTPController) FindByID(c *gin.Context) {
 		log.Println(err.Error())
 	}
 
+	c.Header("ETag", etag.Format(tag.Version))
 	response.Success(c, http.StatusOK, tag)
 }
 
@@ -106,8 +157,12 @@ func (controller *HTTPController) Add(c *gin.Context) {
 		return
 	}
 
-	_, err = controller.tagUseCase.Add(c.Request.Context(), tag)
+	tag, err = controller.tagUseCase.Add(c.Request.Context(), tag)
 	if err != nil {
+		if errors.Is(err, ErrExists) {
+			response.ErrorWithData(c, http.StatusConflict, err, tag)
+			return
+		}
 		response.Error(c, http.StatusInternalServerError, err)
 		return
 	}
@@ -121,19 +176,56 @@ func (controller *HTTPController) Add(c *gin.Context) {
 	response.Success(c, http.StatusOK, nil)
 }
 
+// FindOrCreate returns the tag with the given name, creating it when needed,
+// a created tag is answered with 201.
+func (controller *HTTPController) FindOrCreate(c *gin.Context) {
+	tag, created, err := controller.tagUseCase.FindOrCreate(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+
+		// flush cache
+		if err := controller.cacher.Flush(); err != nil {
+			log.Println(err.Error())
+		}
+	}
+
+	c.Header("ETag", etag.Format(tag.Version))
+	response.Success(c, status, tag)
+}
+
 func (controller *HTTPController) Update(c *gin.Context) {
 	var err error
 	var tag Tag
 
+	version, ok := etag.IfMatch(c)
+	if !ok {
+		return
+	}
+
 	err = c.Bind(&tag)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	_, err = controller.tagUseCase.Update(c.Request.Context(), tag, tools.StringsToInt(c.Param("id")))
+	id := tools.StringsToInt(c.Param("id"))
+	tag.Version = version
+	tag, err = controller.tagUseCase.Update(c.Request.Context(), tag, id)
 	if err != nil {
-		response.Error(c, http.StatusInternalServerError, err)
+		switch {
+		case errors.Is(err, etag.ErrPreconditionFailed):
+			controller.preconditionFailed(c, id)
+		case errors.Is(err, ErrExists):
+			response.ErrorWithData(c, http.StatusConflict, err, tag)
+		default:
+			response.Error(c, http.StatusInternalServerError, err)
+		}
 		return
 	}
 
@@ -143,18 +235,304 @@ func (controller *HTTPController) Update(c *gin.Context) {
 		log.Println(err.Error())
 	}
 
+	c.Header("ETag", etag.Format(tag.Version))
 	response.Success(c, http.StatusOK, nil)
 }
 
+// Patch accepts a JSON Merge Patch (application/merge-patch+json)
+// or a JSON Patch (application/json-patch+json) and returns the updated tag.
+func (controller *HTTPController) Patch(c *gin.Context) {
+	version, ok := etag.IfMatch(c)
+	if !ok {
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	id := tools.StringsToInt(c.Param("id"))
+	tag, err := controller.tagUseCase.Patch(c.Request.Context(), id, version, c.ContentType(), body)
+	if err != nil {
+		switch {
+		case errors.Is(err, etag.ErrPreconditionFailed):
+			controller.preconditionFailed(c, id)
+		case errors.Is(err, ErrExists):
+			response.ErrorWithData(c, http.StatusConflict, err, tag)
+		case errors.Is(err, patch.ErrUnsupportedMediaType):
+			response.Error(c, http.StatusUnsupportedMediaType, err)
+		case errors.Is(err, patch.ErrInvalidPatch):
+			response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			response.Error(c, http.StatusNotFound, fmt.Errorf("record not found"))
+		default:
+			response.Error(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	c.Header("ETag", etag.Format(tag.Version))
+	response.Success(c, http.StatusOK, tag)
+}
+
 func (controller *HTTPController) Delete(c *gin.Context) {
 	var err error
 
-	id := c.Param("id")
-	err = controller.tagUseCase.Delete(c.Request.Context(), tools.StringsToInt(id))
+	version, ok := etag.IfMatch(c)
+	if !ok {
+		return
+	}
+
+	id := tools.StringsToInt(c.Param("id"))
+	err = controller.tagUseCase.Delete(c.Request.Context(), id, version)
+	if err != nil {
+		if errors.Is(err, etag.ErrPreconditionFailed) {
+			controller.preconditionFailed(c, id)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, nil)
+}
+
+// Suggest lists the tags starting with ?prefix, ?limit defaults to 10.
+func (controller *HTTPController) Suggest(c *gin.Context) {
+	prefix := TagKey(c.Query("prefix"))
+	if prefix == "" {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", fmt.Errorf("prefix is required"))
+		return
+	}
+
+	limit := tools.StringsToInt(c.DefaultQuery("limit", tools.IntToString(DefaultSuggestLimit)))
+	if limit < 1 || limit > MaxSuggestLimit {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", fmt.Errorf("limit must be between 1 and %d", MaxSuggestLimit))
+		return
+	}
+
+	// get from cache, keyed on the normalized prefix so "Inv" and "inv " share an entry
+	cache_key := tools.MD5([]byte(fmt.Sprintf("tag_suggest:%s:%d", prefix, limit)))
+	if controller.cacher.IsExist(cache_key) {
+		log.Println("tag | suggest | serve by redis")
+		payload := []Suggestion{}
+		if err := json.Unmarshal([]byte(controller.cacher.Get(cache_key).(string)), &payload); err != nil {
+			response.Error(c, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(c, http.StatusOK, payload)
+		return
+	}
+
+	// get from db
+	suggestions, err := controller.tagUseCase.Suggest(c.Request.Context(), prefix, limit)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+	if suggestions == nil {
+		suggestions = []Suggestion{}
+	}
+
+	// save in cache
+	cache_val, _ := json.Marshal(suggestions)
+	if err := controller.cacher.Put(cache_key, cache_val, 600); err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, suggestions)
+}
+
+// Stats lists the usage of every tag by published news, most used first unless ?sort says otherwise.
+func (controller *HTTPController) Stats(c *gin.Context) {
+	var filter StatsFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	// stats are ordered by counts, rows cannot be paged by id
+	if filter.Cursor != "" {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", fmt.Errorf("stats only support page pagination"))
+		return
+	}
+	if err := filter.Normalize(); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	if filter.Sort == "" {
+		filter.Sort = "-published"
+	}
+	sort, err := sorting.Parse(filter.Sort, StatsSortFields)
+	if err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid sort parameter", err)
+		return
+	}
+
+	// get from cache, saving any news or tag flushes it and the windows roll over within the ttl
+	cache_key := tools.MD5([]byte(fmt.Sprintf("tag_stats:%s:%s", sort, c.Request.URL.Query().Encode())))
+	if controller.cacher.IsExist(cache_key) {
+		log.Println("tag | stats | serve by redis")
+		payload := statsPage{}
+		if err := json.Unmarshal([]byte(controller.cacher.Get(cache_key).(string)), &payload); err != nil {
+			response.Error(c, http.StatusInternalServerError, err)
+			return
+		}
+		response.SuccessWithMeta(c, http.StatusOK, payload.Data, payload.Meta)
+		return
+	}
+
+	// create context
+	ctx := context.WithValue(context.Background(), ContextKey("tag_stats_filter"), filter)
+
+	// get from db
+	stats, total, err := controller.tagUseCase.Stats(ctx)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+	if stats == nil {
+		stats = []Stats{}
+	}
+
+	page := statsPage{
+		Data: stats,
+		Meta: pagination.NewMeta(c.Request.URL, filter.Pagination, total, len(stats), 0, 0),
+	}
+	page.Meta.NextCursor, page.Meta.PrevCursor = "", ""
+
+	// save in cache
+	cache_val, _ := json.Marshal(page)
+	if err := controller.cacher.Put(cache_key, cache_val, 300); err != nil {
+		log.Println(err.Error())
+	}
+
+	response.SuccessWithMeta(c, http.StatusOK, page.Data, page.Meta)
+}
+
+// Cloud returns the ?limit most used tags weighted in ?buckets, ?days only counts recent news.
+func (controller *HTTPController) Cloud(c *gin.Context) {
+	limit := tools.StringsToInt(c.DefaultQuery("limit", tools.IntToString(DefaultCloudLimit)))
+	if limit < 1 || limit > MaxCloudLimit {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", fmt.Errorf("limit must be between 1 and %d", MaxCloudLimit))
+		return
+	}
+
+	buckets := tools.StringsToInt(c.DefaultQuery("buckets", tools.IntToString(DefaultCloudBuckets)))
+	if buckets < 1 || buckets > MaxCloudBuckets {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", fmt.Errorf("buckets must be between 1 and %d", MaxCloudBuckets))
+		return
+	}
+
+	days := tools.StringsToInt(c.DefaultQuery("days", "0"))
+	if days < 0 {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", fmt.Errorf("days must not be negative"))
+		return
+	}
+
+	// get from cache, saving any news or tag flushes it
+	cache_key := tools.MD5([]byte(fmt.Sprintf("tag_cloud:%d:%d:%d", limit, buckets, days)))
+	if controller.cacher.IsExist(cache_key) {
+		log.Println("tag | cloud | serve by redis")
+		payload := []CloudItem{}
+		if err := json.Unmarshal([]byte(controller.cacher.Get(cache_key).(string)), &payload); err != nil {
+			response.Error(c, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(c, http.StatusOK, payload)
+		return
+	}
+
+	// get from db
+	cloud, err := controller.tagUseCase.Cloud(c.Request.Context(), limit, buckets, days)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, err)
 		return
 	}
+	if cloud == nil {
+		cloud = []CloudItem{}
+	}
+
+	// save in cache
+	cache_val, _ := json.Marshal(cloud)
+	if err := controller.cacher.Put(cache_key, cache_val, 300); err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, cloud)
+}
+
+func (controller *HTTPController) FindAliases(c *gin.Context) {
+	aliases, err := controller.tagUseCase.FindAliases(c.Request.Context(), tools.StringsToInt(c.Param("id")))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(c, http.StatusNotFound, fmt.Errorf("record not found"))
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.Success(c, http.StatusOK, aliases)
+}
+
+// AddAlias gives a tag another name, a name owned by another tag answers 409 with that tag.
+func (controller *HTTPController) AddAlias(c *gin.Context) {
+	var dto AliasDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	alias, err := controller.tagUseCase.AddAlias(c.Request.Context(), tools.StringsToInt(c.Param("id")), dto.Alias)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			response.Error(c, http.StatusNotFound, fmt.Errorf("record not found"))
+		case errors.Is(err, ErrExists):
+			response.ErrorWithData(c, http.StatusConflict, err, alias)
+		case errors.Is(err, ErrAliasCycle):
+			response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		default:
+			response.Error(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, alias)
+}
+
+func (controller *HTTPController) DeleteAlias(c *gin.Context) {
+	err := controller.tagUseCase.DeleteAlias(c.Request.Context(), tools.StringsToInt(c.Param("id")), c.Param("alias"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(c, http.StatusNotFound, fmt.Errorf("record not found"))
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
 
 	// flush cache
 	err = controller.cacher.Flush()
@@ -164,3 +542,50 @@ func (controller *HTTPController) Delete(c *gin.Context) {
 
 	response.Success(c, http.StatusOK, nil)
 }
+
+// Merge moves the news of the source tags to the tag and deletes the sources.
+func (controller *HTTPController) Merge(c *gin.Context) {
+	var dto MergeDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	res, err := controller.tagUseCase.Merge(c.Request.Context(), tools.StringsToInt(c.Param("id")), dto)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			response.Error(c, http.StatusNotFound, fmt.Errorf("record not found"))
+		case errors.Is(err, ErrMergeItself):
+			response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		default:
+			response.Error(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	// flush cache, news listing the merged tags are cached too
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, res)
+}
+
+// preconditionFailed answers a write made against a stale version
+// with the current representation of the tag.
+func (controller *HTTPController) preconditionFailed(c *gin.Context, id int) {
+	tag, err := controller.tagUseCase.FindByID(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(c, http.StatusNotFound, fmt.Errorf("record not found"))
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Header("ETag", etag.Format(tag.Version))
+	response.ErrorWithData(c, http.StatusPreconditionFailed, etag.ErrPreconditionFailed, tag)
+}
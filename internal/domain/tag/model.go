@@ -1,18 +1,136 @@
 package tag
 
-import "time"
+import (
+	"strings"
+	"time"
+
+	"github.com/ntm/internal/pkg/common/pagination"
+)
 
 type Tag struct {
 	ID        uint      `gorm:"primaryKey" json:"id,omitempty"`
 	Tag       string    `gorm:"index" json:"tag,omitempty"`
+	TagKey    string    `gorm:"type:varchar(100);uniqueIndex;default:null" json:"-"`
 	CreatedAt time.Time `gorm:"default:current_timestamp;index" json:"created_at,omitempty"`
 	UpdatedAt time.Time `gorm:"default:current_timestamp" json:"updated_at,omitempty"`
+	Version   uint      `gorm:"not null;default:1" json:"version,omitempty"`
+}
+
+// Alias is another name of a tag, it resolves to the tag wherever tags are looked up by name.
+type Alias struct {
+	ID        uint      `gorm:"primaryKey" json:"id,omitempty"`
+	Alias     string    `gorm:"not null;type:varchar(100)" json:"alias,omitempty"`
+	AliasKey  string    `gorm:"not null;type:varchar(100);uniqueIndex" json:"-"`
+	TagID     uint      `gorm:"not null;index" json:"tag_id,omitempty"`
+	Tag       *Tag      `gorm:"constraint:OnDelete:CASCADE" json:"tag,omitempty"`
+	CreatedAt time.Time `gorm:"default:current_timestamp" json:"created_at,omitempty"`
+}
+
+func (Alias) TableName() string {
+	return "tag_aliases"
+}
+
+// AliasDTO names the alias added to a tag.
+type AliasDTO struct {
+	Alias string `json:"alias"`
+}
+
+// Suggestion is a tag matching a typed prefix, Alias is the matching alias
+// when the name of the tag itself does not match.
+type Suggestion struct {
+	ID         uint       `json:"id"`
+	Tag        string     `json:"tag"`
+	Alias      string     `json:"alias,omitempty"`
+	Uses       int64      `json:"uses"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+const (
+	DefaultSuggestLimit = 10
+	MaxSuggestLimit     = 20
+)
+
+// Stats is the usage of a tag by published news, the windows count
+// the news published in the last 7, 30 and 365 days.
+type Stats struct {
+	ID           uint       `json:"id"`
+	Tag          string     `json:"tag"`
+	Published    int64      `json:"published"`
+	Published7d  int64      `gorm:"column:published_7d" json:"published_7d"`
+	Published30d int64      `gorm:"column:published_30d" json:"published_30d"`
+	Published1y  int64      `gorm:"column:published_365d" json:"published_365d"`
+	FirstUsedAt  *time.Time `json:"first_used_at,omitempty"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+}
+
+type StatsFilter struct {
+	Sort string `form:"sort"`
+	pagination.Pagination
 }
 
+// StatsSortFields lists the fields the stats can be sorted by.
+var StatsSortFields = map[string]string{
+	"tag":            "tag",
+	"published":      "published",
+	"published_7d":   "published_7d",
+	"published_30d":  "published_30d",
+	"published_365d": "published_365d",
+	"first_used_at":  "first_used_at",
+	"last_used_at":   "last_used_at",
+}
+
+// CloudItem is a tag of the cloud, Weight goes from 1 for the least used
+// tags to the number of buckets for the most used ones.
+type CloudItem struct {
+	ID        uint   `json:"id"`
+	Tag       string `json:"tag"`
+	Published int64  `json:"published"`
+	Weight    int    `gorm:"-" json:"weight"`
+}
+
+const (
+	DefaultCloudLimit   = 50
+	MaxCloudLimit       = 200
+	DefaultCloudBuckets = 5
+	MaxCloudBuckets     = 10
+)
+
 type Filter struct {
 	Tag          string `form:"tag"`
 	CreatedStart string `form:"created_start"`
 	CreatedEnd   string `form:"created_end"`
+	Sort         string `form:"sort"`
+	pagination.Pagination
+}
+
+// SortFields lists the fields a listing can be sorted by.
+var SortFields = map[string]string{
+	"id":         "id",
+	"tag":        "tag",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
 }
 
 type ContextKey string
+
+// MergeDTO lists the tags merged into another one.
+type MergeDTO struct {
+	Sources []uint `json:"sources"`
+}
+
+// MergeResult reports a merge, News counts the news whose tags changed.
+type MergeResult struct {
+	Tag    Tag    `json:"tag"`
+	Merged []uint `json:"merged"`
+	News   int64  `json:"news"`
+}
+
+// Name trims a tag name and collapses its inner spaces.
+func Name(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// TagKey normalizes a tag name so "Stock", "stock " and "STOCK" are the same tag.
+func TagKey(name string) string {
+	return strings.ToLower(Name(name))
+}
@@ -2,16 +2,55 @@ package tag
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgconn"
+	"github.com/ntm/internal/pkg/common/http/etag"
+	"github.com/ntm/internal/pkg/common/pagination"
+	"github.com/ntm/internal/pkg/common/sorting"
 	"gorm.io/gorm"
 )
 
+// Indexes create the prefix indexes used by Suggest and the news_tags
+// index by tag used to count the news of a tag.
+var Indexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_tags_tag_key_prefix ON tags (tag_key text_pattern_ops)",
+	"CREATE INDEX IF NOT EXISTS idx_tag_aliases_alias_key_prefix ON tag_aliases (alias_key text_pattern_ops)",
+	"CREATE INDEX IF NOT EXISTS idx_news_tags_tag_id ON news_tags (tag_id)",
+}
+
+// published joins the published news of every tag, only published news count as a use
+// and news imports tag so the status is spelled out.
+const published = "LEFT JOIN news ON news.id = news_tags.news_id AND news.status = 'publish' AND news.deleted_at IS NULL"
+
+// uniqueViolation is the postgres error code of a duplicate key.
+const uniqueViolation = "23505"
+
+// suggestion ranking, every published news using a tag counts for one
+// and its weight halves every suggestHalfLifeDays since publication.
+const suggestHalfLifeDays = 30
+
 type Repository interface {
 	GetAll(ctx context.Context) ([]Tag, error)
+	Count(ctx context.Context) (int64, error)
 	GetByID(ctx context.Context, id int) (Tag, error)
+	GetByName(ctx context.Context, name string) (Tag, error)
+	FirstOrCreate(ctx context.Context, name string) (Tag, error)
 	Upsert(ctx context.Context, model Tag) (Tag, error)
 	DeleteByID(ctx context.Context, id int) error
+	DeleteByVersion(ctx context.Context, id int, version uint) error
+	Suggest(ctx context.Context, prefix string, limit int) ([]Suggestion, error)
+	GetStats(ctx context.Context, now time.Time) ([]Stats, error)
+	CountStats(ctx context.Context) (int64, error)
+	GetUsage(ctx context.Context, since *time.Time, limit int) ([]CloudItem, error)
+	GetAliases(ctx context.Context, id int) ([]Alias, error)
+	AddAlias(ctx context.Context, model Alias) (Alias, error)
+	DeleteAlias(ctx context.Context, id int, name string) (bool, error)
+	Merge(ctx context.Context, id uint, sources []uint) (int64, error)
+	MigrateKeys(ctx context.Context) error
 	GetDB() *gorm.DB
 }
 
@@ -25,33 +64,46 @@ func NewRepository(db *gorm.DB) Repository {
 
 func (r *repository) GetAll(ctx context.Context) (res []Tag, err error) {
 	filter := ctx.Value(ContextKey("tags_filter")).(Filter)
+	exec := r.filter(r.db, filter)
+
+	result := exec.Scopes(sorting.Scope(filter.Sort, SortFields), pagination.Scope(filter.Pagination, "id")).Find(&res)
+	if result.Error != nil {
+		return res, result.Error
+	}
+
+	if filter.IsBackward() {
+		for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+			res[i], res[j] = res[j], res[i]
+		}
+	}
 
+	return res, nil
+}
+
+func (r *repository) Count(ctx context.Context) (total int64, err error) {
+	filter := ctx.Value(ContextKey("tags_filter")).(Filter)
+	result := r.filter(r.db.Model(&Tag{}), filter).Count(&total)
+	return total, result.Error
+}
+
+// filter applies the listing conditions shared by GetAll and Count.
+func (r *repository) filter(exec *gorm.DB, filter Filter) *gorm.DB {
 	var dateValid bool
 	_, a := time.Parse("2006-01-02", filter.CreatedStart)
-	_, b := time.Parse("2006-01-02", filter.CreatedStart)
+	_, b := time.Parse("2006-01-02", filter.CreatedEnd)
 	if a == nil && b == nil {
 		dateValid = true
 	}
 
-	var result *gorm.DB
-
 	if filter.Tag != "" {
-		if dateValid {
-			result = r.db.Find(&res, "tag = ? and to_char(created_at, 'YYYY-MM-DD') between ? and ?", filter.Tag, filter.CreatedStart, filter.CreatedEnd)
-			return res, result.Error
-		}
-
-		result = r.db.Find(&res, "tag = ?", filter.Tag)
-		return res, result.Error
+		exec = exec.Where(byName, sql.Named("key", TagKey(filter.Tag)))
 	}
 
 	if dateValid {
-		result = r.db.Find(&res, "to_char(created_at, 'YYYY-MM-DD') between ? and ?", filter.CreatedStart, filter.CreatedEnd)
-		return res, result.Error
+		exec = exec.Where("to_char(created_at, 'YYYY-MM-DD') between ? and ?", filter.CreatedStart, filter.CreatedEnd)
 	}
 
-	result = r.db.Find(&res)
-	return res, result.Error
+	return exec
 }
 
 func (r *repository) GetByID(ctx context.Context, id int) (res Tag, err error) {
@@ -59,9 +111,51 @@ func (r *repository) GetByID(ctx context.Context, id int) (res Tag, err error) {
 	return res, result.Error
 }
 
+// byName matches the tag named @key or the tag having an alias named @key.
+const byName = "tag_key = @key OR id IN (SELECT tag_id FROM tag_aliases WHERE alias_key = @key)"
+
+// GetByName returns the tag with the given name or alias.
+func (r *repository) GetByName(ctx context.Context, name string) (res Tag, err error) {
+	result := r.db.Where(byName, sql.Named("key", TagKey(name))).First(&res)
+	return res, result.Error
+}
+
+// FirstOrCreate returns the tag with the given name or alias, creating it when it does not exist yet.
+func (r *repository) FirstOrCreate(ctx context.Context, name string) (res Tag, err error) {
+	res, err = r.GetByName(ctx, name)
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return res, err
+	}
+
+	// a savepoint keeps the caller's transaction usable when the tag was created concurrently
+	res = Tag{Tag: Name(name), TagKey: TagKey(name)}
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		return translateError(tx.Create(&res).Error)
+	})
+	if errors.Is(err, ErrExists) {
+		return r.GetByName(ctx, name)
+	}
+	return res, err
+}
+
 func (r *repository) Upsert(ctx context.Context, model Tag) (res Tag, err error) {
-	result := r.db.Save(&model)
-	return model, result.Error
+	if model.ID == 0 {
+		result := r.db.Save(&model)
+		return model, translateError(result.Error)
+	}
+
+	// only update the row if nobody else did since it was read
+	version := model.Version
+	model.Version++
+	result := r.db.Model(&model).Where("version = ?", version).Select("*").Updates(&model)
+	if result.Error != nil {
+		return model, translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return model, etag.ErrPreconditionFailed
+	}
+
+	return model, nil
 }
 
 func (r *repository) DeleteByID(ctx context.Context, id int) error {
@@ -69,6 +163,187 @@ func (r *repository) DeleteByID(ctx context.Context, id int) error {
 	return result.Error
 }
 
+func (r *repository) DeleteByVersion(ctx context.Context, id int, version uint) error {
+	result := r.db.Unscoped().Where("version = ?", version).Delete(&Tag{ID: uint(id)})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return etag.ErrPreconditionFailed
+	}
+
+	return nil
+}
+
+// Suggest returns the tags whose key or an alias key starts with prefix,
+// an exact match comes first then the most and most recently used tags.
+func (r *repository) Suggest(ctx context.Context, prefix string, limit int) (res []Suggestion, err error) {
+	const score = "coalesce(sum(power(0.5, extract(epoch from now() - coalesce(news.publish_at, news.created_at)) / 86400 / @half_life)), 0)"
+	const alias = "CASE WHEN tags.tag_key LIKE @like THEN NULL ELSE " +
+		"(SELECT alias FROM tag_aliases WHERE tag_aliases.tag_id = tags.id AND alias_key LIKE @like ORDER BY alias_key LIMIT 1) END"
+
+	like := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix) + "%"
+	err = r.db.Table("tags").
+		Select("tags.id, tags.tag, "+alias+" AS alias, count(news.id) AS uses, "+
+			"max(coalesce(news.publish_at, news.created_at)) AS last_used_at, "+
+			"tags.tag_key = @key AS exact, "+score+" AS score",
+			sql.Named("like", like), sql.Named("key", prefix), sql.Named("half_life", suggestHalfLifeDays),
+		).
+		Joins("LEFT JOIN news_tags ON news_tags.tag_id = tags.id").
+		Joins(published).
+		Where("tags.tag_key LIKE @like OR tags.id IN (SELECT tag_id FROM tag_aliases WHERE alias_key LIKE @like)", sql.Named("like", like)).
+		Group("tags.id").
+		Order("exact DESC, score DESC, tags.tag_key").
+		Limit(limit).
+		Scan(&res).Error
+	return res, err
+}
+
+// GetStats counts the published news of every tag in one pass over news_tags.
+func (r *repository) GetStats(ctx context.Context, now time.Time) (res []Stats, err error) {
+	filter := ctx.Value(ContextKey("tag_stats_filter")).(StatsFilter)
+	err = r.db.Table("tags").
+		Select("tags.id, tags.tag, count(news.id) AS published, "+
+			"count(news.id) FILTER (WHERE news.publish_at >= @d7) AS published_7d, "+
+			"count(news.id) FILTER (WHERE news.publish_at >= @d30) AS published_30d, "+
+			"count(news.id) FILTER (WHERE news.publish_at >= @d365) AS published_365d, "+
+			"min(news.publish_at) AS first_used_at, max(news.publish_at) AS last_used_at",
+			sql.Named("d7", now.AddDate(0, 0, -7)), sql.Named("d30", now.AddDate(0, 0, -30)), sql.Named("d365", now.AddDate(0, 0, -365)),
+		).
+		Joins("LEFT JOIN news_tags ON news_tags.tag_id = tags.id").
+		Joins(published).
+		Group("tags.id").
+		Scopes(sorting.Scope(filter.Sort, StatsSortFields), pagination.Scope(filter.Pagination, "tags.id")).
+		Scan(&res).Error
+	return res, err
+}
+
+func (r *repository) CountStats(ctx context.Context) (total int64, err error) {
+	result := r.db.Model(&Tag{}).Count(&total)
+	return total, result.Error
+}
+
+// GetUsage returns the most used tags by news published since the given time, nil counts every news.
+func (r *repository) GetUsage(ctx context.Context, since *time.Time, limit int) (res []CloudItem, err error) {
+	exec := r.db.Table("tags").
+		Select("tags.id, tags.tag, count(news.id) AS published").
+		Joins("JOIN news_tags ON news_tags.tag_id = tags.id").
+		Joins("JOIN news ON news.id = news_tags.news_id").
+		Where("news.status = ? AND news.deleted_at IS NULL", "publish")
+	if since != nil {
+		exec = exec.Where("news.publish_at >= ?", since)
+	}
+
+	err = exec.Group("tags.id").Order("published DESC, tags.tag_key").Limit(limit).Scan(&res).Error
+	return res, err
+}
+
+func (r *repository) GetAliases(ctx context.Context, id int) (res []Alias, err error) {
+	result := r.db.Where("tag_id = ?", id).Order("alias_key").Find(&res)
+	return res, result.Error
+}
+
+func (r *repository) AddAlias(ctx context.Context, model Alias) (res Alias, err error) {
+	result := r.db.Omit("Tag").Create(&model)
+	return model, result.Error
+}
+
+func (r *repository) DeleteAlias(ctx context.Context, id int, name string) (bool, error) {
+	result := r.db.Where("tag_id = ? and alias_key = ?", id, TagKey(name)).Delete(&Alias{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Merge moves the news of the source tags to the tag id and deletes the sources,
+// the names and aliases of the sources become aliases of the tag.
+// It returns the number of news whose tags changed.
+func (r *repository) Merge(ctx context.Context, id uint, sources []uint) (total int64, err error) {
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		var newsIDs []uint
+		if err := tx.Table("news_tags").Distinct("news_id").Where("tag_id in ?", sources).Pluck("news_id", &newsIDs).Error; err != nil {
+			return err
+		}
+		total = int64(len(newsIDs))
+
+		if len(newsIDs) > 0 {
+			err := tx.Exec("INSERT INTO news_tags (news_id, tag_id) SELECT DISTINCT news_id, ? FROM news_tags WHERE tag_id IN ? ON CONFLICT DO NOTHING", id, sources).Error
+			if err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM news_tags WHERE tag_id IN ?", sources).Error; err != nil {
+				return err
+			}
+
+			// the tags of these news changed, clients holding an old version must read them again
+			if err := tx.Exec("UPDATE news SET version = version + 1 WHERE id IN ?", newsIDs).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec("UPDATE tag_aliases SET tag_id = ? WHERE tag_id IN ?", id, sources).Error; err != nil {
+			return err
+		}
+		err := tx.Exec("INSERT INTO tag_aliases (alias, alias_key, tag_id) SELECT tag, tag_key, ? FROM tags WHERE id IN ? ON CONFLICT DO NOTHING", id, sources).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Where("id in ?", sources).Delete(&Tag{}).Error
+	})
+	return total, err
+}
+
+// MigrateKeys sets the key of the tags saved before keys existed, tags whose
+// names only differ in case or spacing are merged into the oldest one.
+func (r *repository) MigrateKeys(ctx context.Context) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`
+			CREATE TEMP TABLE tag_merges ON COMMIT DROP AS
+			SELECT id, keep_id FROM (
+				SELECT id, min(id) OVER (PARTITION BY key) AS keep_id FROM (
+					SELECT id, coalesce(tag_key, lower(regexp_replace(trim(tag), '\s+', ' ', 'g'))) AS key FROM tags
+				) keys
+			) merges
+			WHERE id <> keep_id`,
+		).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Exec(`
+			INSERT INTO news_tags (news_id, tag_id)
+			SELECT news_tags.news_id, tag_merges.keep_id FROM news_tags
+			JOIN tag_merges ON tag_merges.id = news_tags.tag_id
+			ON CONFLICT DO NOTHING`,
+		).Error
+		if err != nil {
+			return err
+		}
+
+		if err = tx.Exec(`DELETE FROM news_tags WHERE tag_id IN (SELECT id FROM tag_merges)`).Error; err != nil {
+			return err
+		}
+		if err = tx.Exec(`DELETE FROM tags WHERE id IN (SELECT id FROM tag_merges)`).Error; err != nil {
+			return err
+		}
+
+		return tx.Exec(`
+			UPDATE tags SET tag = regexp_replace(trim(tag), '\s+', ' ', 'g'),
+				tag_key = lower(regexp_replace(trim(tag), '\s+', ' ', 'g'))
+			WHERE tag_key IS NULL`,
+		).Error
+	})
+}
+
 func (r *repository) GetDB() *gorm.DB {
 	return r.db
 }
+
+// translateError turns a unique violation, a tag key taken since it was
+// checked, into ErrExists.
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return ErrExists
+	}
+	return err
+}
@@ -2,15 +2,45 @@ package tag
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ntm/internal/pkg/common/http/etag"
+	"github.com/ntm/internal/pkg/common/patch"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrExists is returned with the existing tag when a name is already taken.
+	ErrExists = errors.New("tag already exists")
+
+	// ErrMergeItself is returned when a tag is among the sources merged into it.
+	ErrMergeItself = errors.New("a tag cannot be merged into itself")
+
+	// ErrAliasCycle is returned when an alias would resolve to the name of its own tag.
+	ErrAliasCycle = errors.New("alias resolves to its own tag")
 )
 
 type UseCase interface {
-	FindAll(context context.Context) ([]Tag, error)
+	FindAll(context context.Context) ([]Tag, int64, error)
 	FindByID(context context.Context, id int) (Tag, error)
 	Add(context context.Context, model Tag) (Tag, error)
+	FindOrCreate(context context.Context, name string) (Tag, bool, error)
 	Update(context context.Context, model Tag, id int) (Tag, error)
-	Delete(context context.Context, id int) error
+	Delete(context context.Context, id int, version uint) error
+	Patch(context context.Context, id int, version uint, contentType string, body []byte) (Tag, error)
+	Suggest(context context.Context, prefix string, limit int) ([]Suggestion, error)
+	Stats(context context.Context) ([]Stats, int64, error)
+	Cloud(context context.Context, limit int, buckets int, days int) ([]CloudItem, error)
+	FindAliases(context context.Context, id int) ([]Alias, error)
+	AddAlias(context context.Context, id int, name string) (Alias, error)
+	DeleteAlias(context context.Context, id int, name string) error
+	Merge(context context.Context, id int, dto MergeDTO) (MergeResult, error)
+	MigrateKeys(context context.Context) error
 }
 
 type useCase struct {
@@ -23,9 +53,14 @@ func NewUseCase(repo Repository) UseCase {
 	}
 }
 
-func (us *useCase) FindAll(context context.Context) (res []Tag, err error) {
+func (us *useCase) FindAll(context context.Context) (res []Tag, total int64, err error) {
 	res, err = us.repo.GetAll(context)
-	return res, err
+	if err != nil {
+		return res, total, err
+	}
+
+	total, err = us.repo.Count(context)
+	return res, total, err
 }
 
 func (us *useCase) FindByID(context context.Context, id int) (res Tag, err error) {
@@ -33,16 +68,59 @@ func (us *useCase) FindByID(context context.Context, id int) (res Tag, err error
 	return res, err
 }
 
+// Add creates a tag, a name already taken regardless of case and spacing
+// returns the existing tag with ErrExists.
 func (us *useCase) Add(context context.Context, model Tag) (res Tag, err error) {
+	model.Tag = Name(model.Tag)
 	if model.Tag == "" {
 		return res, fmt.Errorf("invalid parameters")
 	}
 
+	if existing, err := us.repo.GetByName(context, model.Tag); err == nil {
+		return existing, ErrExists
+	}
+
+	model.ID = 0
+	model.TagKey = TagKey(model.Tag)
 	res, err = us.repo.Upsert(context, model)
+	if errors.Is(err, ErrExists) {
+		return us.existing(context, model.Tag)
+	}
 	return res, err
 }
 
+// existing returns the tag that took a name concurrently with ErrExists.
+func (us *useCase) existing(context context.Context, name string) (Tag, error) {
+	res, err := us.repo.GetByName(context, name)
+	if err != nil {
+		return res, err
+	}
+	return res, ErrExists
+}
+
+// FindOrCreate returns the tag with the given name and whether it had to be created.
+func (us *useCase) FindOrCreate(context context.Context, name string) (res Tag, created bool, err error) {
+	name = Name(name)
+	if name == "" {
+		return res, false, fmt.Errorf("invalid parameters")
+	}
+
+	res, err = us.repo.GetByName(context, name)
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return res, false, err
+	}
+
+	res, err = us.repo.Upsert(context, Tag{Tag: name, TagKey: TagKey(name)})
+	if errors.Is(err, ErrExists) {
+		res, err = us.repo.GetByName(context, name)
+		return res, false, err
+	}
+	return res, err == nil, err
+}
+
+// Update replaces the tag, a non zero model.Version must match the stored version.
 func (us *useCase) Update(context context.Context, model Tag, id int) (res Tag, err error) {
+	model.Tag = Name(model.Tag)
 	if model.Tag == "" {
 		return res, fmt.Errorf("invalid parameters")
 	}
@@ -57,14 +135,234 @@ func (us *useCase) Update(context context.Context, model Tag, id int) (res Tag,
 		return res, err
 	}
 
+	if model.Version != 0 && model.Version != res.Version {
+		return res, etag.ErrPreconditionFailed
+	}
+
+	if existing, err := us.repo.GetByName(context, model.Tag); err == nil && existing.ID != res.ID {
+		return existing, ErrExists
+	}
+
+	// a tag renamed to one of its aliases does not need the alias anymore
+	if TagKey(model.Tag) != res.TagKey {
+		if _, err = us.repo.DeleteAlias(context, id, model.Tag); err != nil {
+			return res, err
+		}
+	}
+
 	// update tag
 	res.Tag = model.Tag
+	res.TagKey = TagKey(model.Tag)
 	res, err = us.repo.Upsert(context, res)
+	if errors.Is(err, ErrExists) {
+		return us.existing(context, model.Tag)
+	}
 
 	return res, err
 }
 
-func (us *useCase) Delete(context context.Context, id int) (err error) {
-	err = us.repo.DeleteByID(context, id)
+// Patch applies a merge patch or a json patch on a tag, the patched tag
+// goes through the same validation as Update.
+func (us *useCase) Patch(context context.Context, id int, version uint, contentType string, body []byte) (res Tag, err error) {
+	res, err = us.repo.GetByID(context, id)
+	if err != nil {
+		return res, err
+	}
+
+	doc, err := json.Marshal(res)
+	if err != nil {
+		return res, err
+	}
+
+	patched, err := patch.Apply(contentType, doc, body)
+	if err != nil {
+		return res, err
+	}
+
+	var model Tag
+	if err = json.Unmarshal(patched, &model); err != nil {
+		return res, fmt.Errorf("%w: %s", patch.ErrInvalidPatch, err.Error())
+	}
+
+	// the version comes from If-Match, never from the patch itself
+	model.Version = version
+	res, err = us.Update(context, model, id)
+	return res, err
+}
+
+// Delete removes the tag, unless version is zero it must match the stored version.
+func (us *useCase) Delete(context context.Context, id int, version uint) (err error) {
+	if version == 0 {
+		err = us.repo.DeleteByID(context, id)
+		return err
+	}
+
+	err = us.repo.DeleteByVersion(context, id, version)
 	return err
 }
+
+// Suggest lists the tags starting with prefix for type-ahead.
+func (us *useCase) Suggest(context context.Context, prefix string, limit int) (res []Suggestion, err error) {
+	prefix = TagKey(prefix)
+	if prefix == "" {
+		return res, fmt.Errorf("invalid parameters")
+	}
+
+	res, err = us.repo.Suggest(context, prefix, limit)
+	return res, err
+}
+
+// Stats lists the usage of every tag by published news.
+func (us *useCase) Stats(context context.Context) (res []Stats, total int64, err error) {
+	res, err = us.repo.GetStats(context, time.Now())
+	if err != nil {
+		return res, total, err
+	}
+
+	total, err = us.repo.CountStats(context)
+	return res, total, err
+}
+
+// Cloud weighs the most used tags into buckets and sorts them by name,
+// only the news published in the last days count unless days is zero.
+func (us *useCase) Cloud(context context.Context, limit int, buckets int, days int) (res []CloudItem, err error) {
+	var since *time.Time
+	if days > 0 {
+		t := time.Now().AddDate(0, 0, -days)
+		since = &t
+	}
+
+	res, err = us.repo.GetUsage(context, since, limit)
+	if err != nil {
+		return res, err
+	}
+
+	weigh(res, buckets)
+	sort.SliceStable(res, func(i, j int) bool { return TagKey(res[i].Tag) < TagKey(res[j].Tag) })
+	return res, nil
+}
+
+func (us *useCase) FindAliases(context context.Context, id int) (res []Alias, err error) {
+	if _, err = us.repo.GetByID(context, id); err != nil {
+		return res, err
+	}
+
+	res, err = us.repo.GetAliases(context, id)
+	return res, err
+}
+
+// AddAlias gives the tag another name, a name already used by another tag or alias
+// returns the alias with the tag owning the name and ErrExists.
+func (us *useCase) AddAlias(context context.Context, id int, name string) (res Alias, err error) {
+	name = Name(name)
+	if name == "" {
+		return res, fmt.Errorf("invalid parameters")
+	}
+
+	tag, err := us.repo.GetByID(context, id)
+	if err != nil {
+		return res, err
+	}
+
+	res = Alias{Alias: name, AliasKey: TagKey(name), TagID: tag.ID}
+	if res.AliasKey == tag.TagKey {
+		return res, ErrAliasCycle
+	}
+
+	existing, err := us.repo.GetByName(context, name)
+	if err == nil {
+		if existing.ID == tag.ID {
+			// already an alias of this tag
+			return res, nil
+		}
+		res.TagID, res.Tag = existing.ID, &existing
+		return res, ErrExists
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return res, err
+	}
+
+	res, err = us.repo.AddAlias(context, res)
+	return res, err
+}
+
+func (us *useCase) DeleteAlias(context context.Context, id int, name string) (err error) {
+	deleted, err := us.repo.DeleteAlias(context, id, name)
+	if err == nil && !deleted {
+		err = gorm.ErrRecordNotFound
+	}
+	return err
+}
+
+// Merge moves the news of the source tags to the tag id, the sources are deleted
+// and their names kept as aliases of the tag.
+func (us *useCase) Merge(context context.Context, id int, dto MergeDTO) (res MergeResult, err error) {
+	if len(dto.Sources) == 0 {
+		return res, fmt.Errorf("invalid parameters")
+	}
+
+	res.Tag, err = us.repo.GetByID(context, id)
+	if err != nil {
+		return res, err
+	}
+
+	res.Merged = []uint{}
+	for _, v := range dto.Sources {
+		if v == res.Tag.ID {
+			return res, ErrMergeItself
+		}
+		if containsID(res.Merged, v) {
+			continue
+		}
+		if _, err = us.repo.GetByID(context, int(v)); err != nil {
+			return res, err
+		}
+		res.Merged = append(res.Merged, v)
+	}
+
+	res.News, err = us.repo.Merge(context, res.Tag.ID, res.Merged)
+	return res, err
+}
+
+// MigrateKeys normalizes the tags saved before tag names were unique.
+func (us *useCase) MigrateKeys(context context.Context) (err error) {
+	err = us.repo.MigrateKeys(context)
+	return err
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// weigh spreads the usage counts over buckets on a log scale,
+// a few very popular tags would otherwise flatten every other tag.
+func weigh(items []CloudItem, buckets int) {
+	if len(items) == 0 {
+		return
+	}
+
+	min, max := items[0].Published, items[0].Published
+	for _, v := range items {
+		if v.Published < min {
+			min = v.Published
+		}
+		if v.Published > max {
+			max = v.Published
+		}
+	}
+
+	spread := math.Log(float64(max)) - math.Log(float64(min))
+	for i := range items {
+		if spread == 0 {
+			items[i].Weight = buckets
+			continue
+		}
+		ratio := (math.Log(float64(items[i].Published)) - math.Log(float64(min))) / spread
+		items[i].Weight = 1 + int(math.Floor(ratio*float64(buckets-1)+0.5))
+	}
+}
@@ -3,16 +3,28 @@ package topic
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ntm/internal/infrastructure/cache"
+	"github.com/ntm/internal/pkg/common/http/etag"
 	"github.com/ntm/internal/pkg/common/http/response"
+	"github.com/ntm/internal/pkg/common/pagination"
+	"github.com/ntm/internal/pkg/common/patch"
+	"github.com/ntm/internal/pkg/common/sorting"
 	"github.com/ntm/internal/tools"
+	"gorm.io/gorm"
 )
 
+// topicPage is the cached form of a paginated listing.
+type topicPage struct {
+	Data []Topic         `json:"data"`
+	Meta pagination.Meta `json:"meta"`
+}
+
 type HTTPController struct {
 	topicUseCase UseCase
 	cacher       cache.Cacher
@@ -32,16 +44,32 @@ func (controller *HTTPController) FindAll(c *gin.Context) {
 		return
 	}
 
+	if err := filter.Normalize(); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	// validate sort order
+	sort, err := sorting.Parse(filter.Sort, SortFields)
+	if err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid sort parameter", err)
+		return
+	}
+	if len(sort) > 0 && filter.Cursor != "" {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid sort parameter", fmt.Errorf("cursor pagination only supports the default sort order"))
+		return
+	}
+
 	// get from cache
-	cache_key := tools.MD5([]byte(fmt.Sprintf("topics:%s", c.Request.URL.Query().Encode())))
+	cache_key := tools.MD5([]byte(fmt.Sprintf("topics:%s:%s", sort, c.Request.URL.Query().Encode())))
 	if controller.cacher.IsExist(cache_key) {
 		log.Println("topic | findAll | serve by redis")
-		payload := []Topic{}
+		payload := topicPage{}
 		if err := json.Unmarshal([]byte(controller.cacher.Get(cache_key).(string)), &payload); err != nil {
 			response.Error(c, http.StatusInternalServerError, err)
 			return
 		}
-		response.Success(c, http.StatusOK, payload)
+		response.SuccessWithMeta(c, http.StatusOK, payload.Data, payload.Meta)
 		return
 	}
 
@@ -49,19 +77,34 @@ func (controller *HTTPController) FindAll(c *gin.Context) {
 	ctx := context.WithValue(context.Background(), ContextKey("topics_filter"), filter)
 
 	// get from db
-	topics, err := controller.topicUseCase.FindAll(ctx)
+	topics, total, err := controller.topicUseCase.FindAll(ctx)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, err)
 		return
 	}
 
+	// build pagination meta
+	var firstID, lastID uint
+	if len(topics) > 0 {
+		firstID, lastID = topics[0].ID, topics[len(topics)-1].ID
+	}
+	page := topicPage{
+		Data: topics,
+		Meta: pagination.NewMeta(c.Request.URL, filter.Pagination, total, len(topics), firstID, lastID),
+	}
+
+	// cursors are keyed on id and only valid for the default sort order
+	if len(sort) > 0 {
+		page.Meta.NextCursor, page.Meta.PrevCursor = "", ""
+	}
+
 	// save in cache
-	cache_val, _ := json.Marshal(topics)
+	cache_val, _ := json.Marshal(page)
 	if err := controller.cacher.Put(cache_key, cache_val, 600); err != nil {
 		log.Println(err.Error())
 	}
 
-	response.Success(c, http.StatusOK, topics)
+	response.SuccessWithMeta(c, http.StatusOK, page.Data, page.Meta)
 }
 
 func (controller *HTTPController) FindByID(c *gin.Context) {
@@ -76,6 +119,7 @@ func (controller *HTTPController) FindByID(c *gin.Context) {
 			response.Error(c, http.StatusInternalServerError, err)
 			return
 		}
+		c.Header("ETag", etag.Format(payload.Version))
 		response.Success(c, http.StatusOK, payload)
 		return
 	}
@@ -93,6 +137,7 @@ func (controller *HTTPController) FindByID(c *gin.Context) {
 		log.Println(err.Error())
 	}
 
+	c.Header("ETag", etag.Format(topic.Version))
 	response.Success(c, http.StatusOK, topic)
 }
 
@@ -125,14 +170,25 @@ func (controller *HTTPController) Update(c *gin.Context) {
 	var err error
 	var topic Topic
 
+	version, ok := etag.IfMatch(c)
+	if !ok {
+		return
+	}
+
 	err = c.Bind(&topic)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	_, err = controller.topicUseCase.Update(c.Request.Context(), topic, tools.StringsToInt(c.Param("id")))
+	id := tools.StringsToInt(c.Param("id"))
+	topic.Version = version
+	topic, err = controller.topicUseCase.Update(c.Request.Context(), topic, id)
 	if err != nil {
+		if errors.Is(err, etag.ErrPreconditionFailed) {
+			controller.preconditionFailed(c, id)
+			return
+		}
 		response.Error(c, http.StatusInternalServerError, err)
 		return
 	}
@@ -143,15 +199,67 @@ func (controller *HTTPController) Update(c *gin.Context) {
 		log.Println(err.Error())
 	}
 
+	c.Header("ETag", etag.Format(topic.Version))
 	response.Success(c, http.StatusOK, nil)
 }
 
+// Patch accepts a JSON Merge Patch (application/merge-patch+json)
+// or a JSON Patch (application/json-patch+json) and returns the updated topic.
+func (controller *HTTPController) Patch(c *gin.Context) {
+	version, ok := etag.IfMatch(c)
+	if !ok {
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	id := tools.StringsToInt(c.Param("id"))
+	topic, err := controller.topicUseCase.Patch(c.Request.Context(), id, version, c.ContentType(), body)
+	if err != nil {
+		switch {
+		case errors.Is(err, etag.ErrPreconditionFailed):
+			controller.preconditionFailed(c, id)
+		case errors.Is(err, patch.ErrUnsupportedMediaType):
+			response.Error(c, http.StatusUnsupportedMediaType, err)
+		case errors.Is(err, patch.ErrInvalidPatch):
+			response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			response.Error(c, http.StatusNotFound, fmt.Errorf("record not found"))
+		default:
+			response.Error(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	c.Header("ETag", etag.Format(topic.Version))
+	response.Success(c, http.StatusOK, topic)
+}
+
 func (controller *HTTPController) Delete(c *gin.Context) {
 	var err error
 
-	id := c.Param("id")
-	err = controller.topicUseCase.Delete(c.Request.Context(), tools.StringsToInt(id))
+	version, ok := etag.IfMatch(c)
+	if !ok {
+		return
+	}
+
+	id := tools.StringsToInt(c.Param("id"))
+	err = controller.topicUseCase.Delete(c.Request.Context(), id, version)
 	if err != nil {
+		if errors.Is(err, etag.ErrPreconditionFailed) {
+			controller.preconditionFailed(c, id)
+			return
+		}
 		response.Error(c, http.StatusInternalServerError, err)
 		return
 	}
@@ -164,3 +272,20 @@ func (controller *HTTPController) Delete(c *gin.Context) {
 
 	response.Success(c, http.StatusOK, nil)
 }
+
+// preconditionFailed answers a write made against a stale version
+// with the current representation of the topic.
+func (controller *HTTPController) preconditionFailed(c *gin.Context, id int) {
+	topic, err := controller.topicUseCase.FindByID(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(c, http.StatusNotFound, fmt.Errorf("record not found"))
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Header("ETag", etag.Format(topic.Version))
+	response.ErrorWithData(c, http.StatusPreconditionFailed, etag.ErrPreconditionFailed, topic)
+}
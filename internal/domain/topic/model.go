@@ -1,18 +1,33 @@
 package topic
 
-import "time"
+import (
+	"time"
+
+	"github.com/ntm/internal/pkg/common/pagination"
+)
 
 type Topic struct {
 	ID        uint      `gorm:"primaryKey" json:"id,omitempty"`
 	Topic     string    `gorm:"index" json:"topic,omitempty"`
 	CreatedAt time.Time `gorm:"default:current_timestamp;index" json:"created_at,omitempty"`
 	UpdatedAt time.Time `gorm:"default:current_timestamp" json:"updated_at,omitempty"`
+	Version   uint      `gorm:"not null;default:1" json:"version,omitempty"`
 }
 
 type Filter struct {
 	Topic        string `form:"topic"`
 	CreatedStart string `form:"created_start"`
 	CreatedEnd   string `form:"created_end"`
+	Sort         string `form:"sort"`
+	pagination.Pagination
+}
+
+// SortFields lists the fields a listing can be sorted by.
+var SortFields = map[string]string{
+	"id":         "id",
+	"topic":      "topic",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
 }
 
 type ContextKey string
@@ -4,14 +4,19 @@ import (
 	"context"
 	"time"
 
+	"github.com/ntm/internal/pkg/common/http/etag"
+	"github.com/ntm/internal/pkg/common/pagination"
+	"github.com/ntm/internal/pkg/common/sorting"
 	"gorm.io/gorm"
 )
 
 type Repository interface {
 	GetAll(ctx context.Context) ([]Topic, error)
+	Count(ctx context.Context) (int64, error)
 	GetByID(ctx context.Context, id int) (Topic, error)
 	Upsert(ctx context.Context, model Topic) (Topic, error)
 	DeleteByID(ctx context.Context, id int) error
+	DeleteByVersion(ctx context.Context, id int, version uint) error
 	GetDB() *gorm.DB
 }
 
@@ -25,33 +30,46 @@ func NewRepository(db *gorm.DB) Repository {
 
 func (r *repository) GetAll(ctx context.Context) (res []Topic, err error) {
 	filter := ctx.Value(ContextKey("topics_filter")).(Filter)
+	exec := r.filter(r.db, filter)
 
+	result := exec.Scopes(sorting.Scope(filter.Sort, SortFields), pagination.Scope(filter.Pagination, "id")).Find(&res)
+	if result.Error != nil {
+		return res, result.Error
+	}
+
+	if filter.IsBackward() {
+		for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+			res[i], res[j] = res[j], res[i]
+		}
+	}
+
+	return res, nil
+}
+
+func (r *repository) Count(ctx context.Context) (total int64, err error) {
+	filter := ctx.Value(ContextKey("topics_filter")).(Filter)
+	result := r.filter(r.db.Model(&Topic{}), filter).Count(&total)
+	return total, result.Error
+}
+
+// filter applies the listing conditions shared by GetAll and Count.
+func (r *repository) filter(exec *gorm.DB, filter Filter) *gorm.DB {
 	var dateValid bool
 	_, a := time.Parse("2006-01-02", filter.CreatedStart)
-	_, b := time.Parse("2006-01-02", filter.CreatedStart)
+	_, b := time.Parse("2006-01-02", filter.CreatedEnd)
 	if a == nil && b == nil {
 		dateValid = true
 	}
 
-	var result *gorm.DB
-
 	if filter.Topic != "" {
-		if dateValid {
-			result = r.db.Find(&res, "topic = ? and to_char(created_at, 'YYYY-MM-DD') between ? and ?", filter.Topic, filter.CreatedStart, filter.CreatedEnd)
-			return res, result.Error
-		}
-
-		result = r.db.Find(&res, "topic = ?", filter.Topic)
-		return res, result.Error
+		exec = exec.Where("topic = ?", filter.Topic)
 	}
 
 	if dateValid {
-		result = r.db.Find(&res, "to_char(created_at, 'YYYY-MM-DD') between ? and ?", filter.CreatedStart, filter.CreatedEnd)
-		return res, result.Error
+		exec = exec.Where("to_char(created_at, 'YYYY-MM-DD') between ? and ?", filter.CreatedStart, filter.CreatedEnd)
 	}
 
-	result = r.db.Find(&res)
-	return res, result.Error
+	return exec
 }
 
 func (r *repository) GetByID(ctx context.Context, id int) (res Topic, err error) {
@@ -60,8 +78,23 @@ func (r *repository) GetByID(ctx context.Context, id int) (res Topic, err error)
 }
 
 func (r *repository) Upsert(ctx context.Context, model Topic) (res Topic, err error) {
-	result := r.db.Save(&model)
-	return model, result.Error
+	if model.ID == 0 {
+		result := r.db.Save(&model)
+		return model, result.Error
+	}
+
+	// only update the row if nobody else did since it was read
+	version := model.Version
+	model.Version++
+	result := r.db.Model(&model).Where("version = ?", version).Select("*").Updates(&model)
+	if result.Error != nil {
+		return model, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return model, etag.ErrPreconditionFailed
+	}
+
+	return model, nil
 }
 
 func (r *repository) DeleteByID(ctx context.Context, id int) error {
@@ -69,6 +102,18 @@ func (r *repository) DeleteByID(ctx context.Context, id int) error {
 	return result.Error
 }
 
+func (r *repository) DeleteByVersion(ctx context.Context, id int, version uint) error {
+	result := r.db.Unscoped().Where("version = ?", version).Delete(&Topic{ID: uint(id)})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return etag.ErrPreconditionFailed
+	}
+
+	return nil
+}
+
 func (r *repository) GetDB() *gorm.DB {
 	return r.db
 }
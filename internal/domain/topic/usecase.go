@@ -2,15 +2,20 @@ package topic
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+
+	"github.com/ntm/internal/pkg/common/http/etag"
+	"github.com/ntm/internal/pkg/common/patch"
 )
 
 type UseCase interface {
-	FindAll(context context.Context) ([]Topic, error)
+	FindAll(context context.Context) ([]Topic, int64, error)
 	FindByID(context context.Context, id int) (Topic, error)
 	Add(context context.Context, model Topic) (Topic, error)
 	Update(context context.Context, model Topic, id int) (Topic, error)
-	Delete(context context.Context, id int) error
+	Delete(context context.Context, id int, version uint) error
+	Patch(context context.Context, id int, version uint, contentType string, body []byte) (Topic, error)
 }
 
 type useCase struct {
@@ -23,9 +28,14 @@ func NewUseCase(repo Repository) UseCase {
 	}
 }
 
-func (us *useCase) FindAll(context context.Context) (res []Topic, err error) {
+func (us *useCase) FindAll(context context.Context) (res []Topic, total int64, err error) {
 	res, err = us.repo.GetAll(context)
-	return res, err
+	if err != nil {
+		return res, total, err
+	}
+
+	total, err = us.repo.Count(context)
+	return res, total, err
 }
 
 func (us *useCase) FindByID(context context.Context, id int) (res Topic, err error) {
@@ -41,6 +51,7 @@ func (us *useCase) Add(context context.Context, model Topic) (res Topic, err err
 	return res, err
 }
 
+// Update replaces the topic, a non zero model.Version must match the stored version.
 func (us *useCase) Update(context context.Context, model Topic, id int) (res Topic, err error) {
 	if model.Topic == "" {
 		return res, fmt.Errorf("invalid parameters")
@@ -56,6 +67,10 @@ func (us *useCase) Update(context context.Context, model Topic, id int) (res Top
 		return res, err
 	}
 
+	if model.Version != 0 && model.Version != res.Version {
+		return res, etag.ErrPreconditionFailed
+	}
+
 	// update tag
 	res.Topic = model.Topic
 	res, err = us.repo.Upsert(context, res)
@@ -63,7 +78,42 @@ func (us *useCase) Update(context context.Context, model Topic, id int) (res Top
 	return res, err
 }
 
-func (us *useCase) Delete(context context.Context, id int) (err error) {
-	err = us.repo.DeleteByID(context, id)
+// Patch applies a merge patch or a json patch on a topic, the patched topic
+// goes through the same validation as Update.
+func (us *useCase) Patch(context context.Context, id int, version uint, contentType string, body []byte) (res Topic, err error) {
+	res, err = us.repo.GetByID(context, id)
+	if err != nil {
+		return res, err
+	}
+
+	doc, err := json.Marshal(res)
+	if err != nil {
+		return res, err
+	}
+
+	patched, err := patch.Apply(contentType, doc, body)
+	if err != nil {
+		return res, err
+	}
+
+	var model Topic
+	if err = json.Unmarshal(patched, &model); err != nil {
+		return res, fmt.Errorf("%w: %s", patch.ErrInvalidPatch, err.Error())
+	}
+
+	// the version comes from If-Match, never from the patch itself
+	model.Version = version
+	res, err = us.Update(context, model, id)
+	return res, err
+}
+
+// Delete removes the topic, unless version is zero it must match the stored version.
+func (us *useCase) Delete(context context.Context, id int, version uint) (err error) {
+	if version == 0 {
+		err = us.repo.DeleteByID(context, id)
+		return err
+	}
+
+	err = us.repo.DeleteByVersion(context, id, version)
 	return err
 }
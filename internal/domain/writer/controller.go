@@ -0,0 +1,210 @@
+package writer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ntm/internal/infrastructure/cache"
+	"github.com/ntm/internal/pkg/common/http/response"
+	"github.com/ntm/internal/pkg/common/pagination"
+	"github.com/ntm/internal/pkg/common/sorting"
+	"github.com/ntm/internal/tools"
+)
+
+// writerPage is the cached form of a paginated listing.
+type writerPage struct {
+	Data []Writer        `json:"data"`
+	Meta pagination.Meta `json:"meta"`
+}
+
+type HTTPController struct {
+	writerUseCase UseCase
+	cacher        cache.Cacher
+}
+
+func NewHTTPController(writerUseCase UseCase, cacher cache.Cacher) *HTTPController {
+	return &HTTPController{
+		writerUseCase: writerUseCase,
+		cacher:        cacher,
+	}
+}
+
+func (controller *HTTPController) FindAll(c *gin.Context) {
+	var filter Filter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	if err := filter.Normalize(); err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return
+	}
+
+	// validate sort order
+	sort, err := sorting.Parse(filter.Sort, SortFields)
+	if err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid sort parameter", err)
+		return
+	}
+	if len(sort) > 0 && filter.Cursor != "" {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid sort parameter", fmt.Errorf("cursor pagination only supports the default sort order"))
+		return
+	}
+
+	// get from cache
+	cache_key := tools.MD5([]byte(fmt.Sprintf("writers:%s:%s", sort, c.Request.URL.Query().Encode())))
+	if controller.cacher.IsExist(cache_key) {
+		log.Println("writer | findAll | serve by redis")
+		payload := writerPage{}
+		if err := json.Unmarshal([]byte(controller.cacher.Get(cache_key).(string)), &payload); err != nil {
+			response.Error(c, http.StatusInternalServerError, err)
+			return
+		}
+		response.SuccessWithMeta(c, http.StatusOK, payload.Data, payload.Meta)
+		return
+	}
+
+	// create context
+	ctx := context.WithValue(context.Background(), ContextKey("writers_filter"), filter)
+
+	// get from db
+	writers, total, err := controller.writerUseCase.FindAll(ctx)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// build pagination meta
+	var firstID, lastID uint
+	if len(writers) > 0 {
+		firstID, lastID = writers[0].ID, writers[len(writers)-1].ID
+	}
+	page := writerPage{
+		Data: writers,
+		Meta: pagination.NewMeta(c.Request.URL, filter.Pagination, total, len(writers), firstID, lastID),
+	}
+
+	// cursors are keyed on id and only valid for the default sort order
+	if len(sort) > 0 {
+		page.Meta.NextCursor, page.Meta.PrevCursor = "", ""
+	}
+
+	// save in cache
+	cache_val, _ := json.Marshal(page)
+	if err := controller.cacher.Put(cache_key, cache_val, 600); err != nil {
+		log.Println(err.Error())
+	}
+
+	response.SuccessWithMeta(c, http.StatusOK, page.Data, page.Meta)
+}
+
+func (controller *HTTPController) FindByID(c *gin.Context) {
+	id := c.Param("id")
+
+	// get from cache
+	cache_key := tools.MD5([]byte(fmt.Sprintf("writer_id:" + id)))
+	if controller.cacher.IsExist(cache_key) {
+		log.Println("writer | findByID | serve by redis")
+		payload := Writer{}
+		if err := json.Unmarshal([]byte(controller.cacher.Get(cache_key).(string)), &payload); err != nil {
+			response.Error(c, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(c, http.StatusOK, payload)
+		return
+	}
+
+	// get from db
+	writer, err := controller.writerUseCase.FindByID(c.Request.Context(), tools.StringsToInt(id))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// save in cache
+	cache_val, _ := json.Marshal(writer)
+	if err := controller.cacher.Put(cache_key, cache_val, 600); err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, writer)
+}
+
+func (controller *HTTPController) Add(c *gin.Context) {
+	var err error
+	var writer Writer
+
+	err = c.Bind(&writer)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	_, err = controller.writerUseCase.Add(c.Request.Context(), writer)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, nil)
+}
+
+func (controller *HTTPController) Update(c *gin.Context) {
+	var err error
+	var writer Writer
+
+	err = c.Bind(&writer)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	_, err = controller.writerUseCase.Update(c.Request.Context(), writer, tools.StringsToInt(c.Param("id")))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, nil)
+}
+
+func (controller *HTTPController) Delete(c *gin.Context) {
+	var err error
+
+	id := c.Param("id")
+	err = controller.writerUseCase.Delete(c.Request.Context(), tools.StringsToInt(id))
+	if err != nil {
+		if errors.Is(err, ErrHasNews) {
+			response.Error(c, http.StatusConflict, err)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// flush cache
+	err = controller.cacher.Flush()
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	response.Success(c, http.StatusOK, nil)
+}
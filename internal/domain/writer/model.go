@@ -0,0 +1,41 @@
+package writer
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ntm/internal/pkg/common/pagination"
+)
+
+type Writer struct {
+	ID        uint      `gorm:"primaryKey" json:"id,omitempty"`
+	Name      string    `gorm:"not null;type:varchar(100)" json:"name,omitempty"`
+	NameKey   string    `gorm:"not null;type:varchar(100);uniqueIndex" json:"-"`
+	Bio       string    `json:"bio,omitempty"`
+	Avatar    string    `gorm:"type:varchar(255)" json:"avatar,omitempty"`
+	CreatedAt time.Time `gorm:"default:current_timestamp;index" json:"created_at,omitempty"`
+	UpdatedAt time.Time `gorm:"default:current_timestamp" json:"updated_at,omitempty"`
+}
+
+type Filter struct {
+	Name         string `form:"name"`
+	CreatedStart string `form:"created_start"`
+	CreatedEnd   string `form:"created_end"`
+	Sort         string `form:"sort"`
+	pagination.Pagination
+}
+
+// SortFields lists the fields a listing can be sorted by.
+var SortFields = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+type ContextKey string
+
+// NameKey normalizes a writer name so "Setia Budi" and " setia  budi" are the same writer.
+func NameKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
@@ -0,0 +1,119 @@
+package writer
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/ntm/internal/pkg/common/pagination"
+	"github.com/ntm/internal/pkg/common/sorting"
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	GetAll(ctx context.Context) ([]Writer, error)
+	Count(ctx context.Context) (int64, error)
+	GetByID(ctx context.Context, id int) (Writer, error)
+	GetByName(ctx context.Context, name string) (Writer, error)
+	FirstOrCreate(ctx context.Context, name string) (Writer, error)
+	CountNews(ctx context.Context, id int) (int64, error)
+	RenameNews(ctx context.Context, id int, name string) error
+	Upsert(ctx context.Context, model Writer) (Writer, error)
+	DeleteByID(ctx context.Context, id int) error
+	GetDB() *gorm.DB
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db}
+}
+
+func (r *repository) GetAll(ctx context.Context) (res []Writer, err error) {
+	filter := ctx.Value(ContextKey("writers_filter")).(Filter)
+	exec := r.filter(r.db, filter)
+
+	result := exec.Scopes(sorting.Scope(filter.Sort, SortFields), pagination.Scope(filter.Pagination, "id")).Find(&res)
+	if result.Error != nil {
+		return res, result.Error
+	}
+
+	if filter.IsBackward() {
+		for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+			res[i], res[j] = res[j], res[i]
+		}
+	}
+
+	return res, nil
+}
+
+func (r *repository) Count(ctx context.Context) (total int64, err error) {
+	filter := ctx.Value(ContextKey("writers_filter")).(Filter)
+	result := r.filter(r.db.Model(&Writer{}), filter).Count(&total)
+	return total, result.Error
+}
+
+// filter applies the listing conditions shared by GetAll and Count.
+func (r *repository) filter(exec *gorm.DB, filter Filter) *gorm.DB {
+	var dateValid bool
+	_, a := time.Parse("2006-01-02", filter.CreatedStart)
+	_, b := time.Parse("2006-01-02", filter.CreatedEnd)
+	if a == nil && b == nil {
+		dateValid = true
+	}
+
+	if filter.Name != "" {
+		exec = exec.Where("name_key = ?", NameKey(filter.Name))
+	}
+
+	if dateValid {
+		exec = exec.Where("to_char(created_at, 'YYYY-MM-DD') between ? and ?", filter.CreatedStart, filter.CreatedEnd)
+	}
+
+	return exec
+}
+
+func (r *repository) GetByID(ctx context.Context, id int) (res Writer, err error) {
+	result := r.db.First(&res, id)
+	return res, result.Error
+}
+
+func (r *repository) GetByName(ctx context.Context, name string) (res Writer, err error) {
+	result := r.db.Where("name_key = ?", NameKey(name)).First(&res)
+	return res, result.Error
+}
+
+// FirstOrCreate returns the writer with the given name, creating it when it does not exist yet.
+func (r *repository) FirstOrCreate(ctx context.Context, name string) (res Writer, err error) {
+	name = strings.Join(strings.Fields(name), " ")
+	result := r.db.Where(Writer{NameKey: NameKey(name)}).Attrs(Writer{Name: name}).FirstOrCreate(&res)
+	return res, result.Error
+}
+
+// CountNews counts the news of a writer, trashed or not.
+func (r *repository) CountNews(ctx context.Context, id int) (total int64, err error) {
+	result := r.db.Table("news").Where("writer_id = ?", id).Count(&total)
+	return total, result.Error
+}
+
+// RenameNews copies the writer name into the denormalized writer column of its news.
+func (r *repository) RenameNews(ctx context.Context, id int, name string) error {
+	result := r.db.Exec("UPDATE news SET writer = ?, version = version + 1 WHERE writer_id = ? AND writer IS DISTINCT FROM ?", name, id, name)
+	return result.Error
+}
+
+func (r *repository) Upsert(ctx context.Context, model Writer) (res Writer, err error) {
+	result := r.db.Save(&model)
+	return model, result.Error
+}
+
+func (r *repository) DeleteByID(ctx context.Context, id int) error {
+	result := r.db.Unscoped().Delete(&Writer{ID: uint(id)})
+	return result.Error
+}
+
+func (r *repository) GetDB() *gorm.DB {
+	return r.db
+}
@@ -0,0 +1,121 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrHasNews is returned when deleting a writer still referenced by news, trashed ones included.
+var ErrHasNews = errors.New("writer still has news")
+
+type UseCase interface {
+	FindAll(context context.Context) ([]Writer, int64, error)
+	FindByID(context context.Context, id int) (Writer, error)
+	Add(context context.Context, model Writer) (Writer, error)
+	Update(context context.Context, model Writer, id int) (Writer, error)
+	Delete(context context.Context, id int) error
+}
+
+type useCase struct {
+	repo Repository
+}
+
+func NewUseCase(repo Repository) UseCase {
+	return &useCase{
+		repo: repo,
+	}
+}
+
+func (us *useCase) FindAll(context context.Context) (res []Writer, total int64, err error) {
+	res, err = us.repo.GetAll(context)
+	if err != nil {
+		return res, total, err
+	}
+
+	total, err = us.repo.Count(context)
+	return res, total, err
+}
+
+func (us *useCase) FindByID(context context.Context, id int) (res Writer, err error) {
+	res, err = us.repo.GetByID(context, id)
+	return res, err
+}
+
+func (us *useCase) Add(context context.Context, model Writer) (res Writer, err error) {
+	model.Name = strings.Join(strings.Fields(model.Name), " ")
+	if model.Name == "" {
+		return res, fmt.Errorf("invalid parameters")
+	}
+
+	// writer names are unique regardless of case and spacing
+	if _, err = us.repo.GetByName(context, model.Name); err == nil {
+		return res, fmt.Errorf("writer %q already exists", model.Name)
+	}
+
+	model.ID = 0
+	model.NameKey = NameKey(model.Name)
+	res, err = us.repo.Upsert(context, model)
+	return res, err
+}
+
+func (us *useCase) Update(context context.Context, model Writer, id int) (res Writer, err error) {
+	model.Name = strings.Join(strings.Fields(model.Name), " ")
+	if model.Name == "" {
+		return res, fmt.Errorf("invalid parameters")
+	}
+
+	if id == 0 {
+		return res, fmt.Errorf("invalid parameters")
+	}
+
+	// get id first
+	res, err = us.repo.GetByID(context, id)
+	if err != nil {
+		return res, err
+	}
+
+	if existing, err := us.repo.GetByName(context, model.Name); err == nil && existing.ID != res.ID {
+		return res, fmt.Errorf("writer %q already exists", model.Name)
+	}
+
+	// update writer, a rename is copied to its news in the same transaction
+	renamed := res.Name != model.Name
+	res.Name = model.Name
+	res.NameKey = NameKey(model.Name)
+	res.Bio = model.Bio
+	res.Avatar = model.Avatar
+	err = us.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		txRepo := NewRepository(tx)
+
+		res, err = txRepo.Upsert(context, res)
+		if err != nil {
+			return err
+		}
+
+		if renamed {
+			return txRepo.RenameNews(context, int(res.ID), res.Name)
+		}
+
+		return nil
+	})
+
+	return res, err
+}
+
+func (us *useCase) Delete(context context.Context, id int) (err error) {
+	// writers with news cannot be deleted, trashed news can still be restored
+	total, err := us.repo.CountNews(context, id)
+	if err != nil {
+		return err
+	}
+	if total > 0 {
+		return fmt.Errorf("%w: %d news, trashed or not", ErrHasNews, total)
+	}
+
+	err = us.repo.DeleteByID(context, id)
+	return err
+}
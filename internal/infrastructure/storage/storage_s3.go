@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+type StorageS3 struct {
+	client  *minio.Client
+	bucket  string
+	baseURL string
+}
+
+// NewStorageS3 stores files in a bucket of any S3 compatible service,
+// baseURL is where the bucket is publicly served from.
+func NewStorageS3(client *minio.Client, bucket string, baseURL string) *StorageS3 {
+	if baseURL == "" {
+		baseURL = client.EndpointURL().String() + "/" + bucket
+	}
+	return &StorageS3{client: client, bucket: bucket, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+func (s *StorageS3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (s *StorageS3) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *StorageS3) URL(key string) string {
+	return s.baseURL + "/" + key
+}
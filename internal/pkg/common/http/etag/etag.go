@@ -0,0 +1,63 @@
+package etag
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ntm/internal/pkg/common/http/response"
+)
+
+var (
+	ErrPreconditionFailed   = errors.New("resource has been modified since it was read")
+	ErrPreconditionRequired = errors.New("If-Match header is required")
+)
+
+// Format returns the strong entity tag of a resource version.
+func Format(version uint) string {
+	return strconv.Quote(strconv.FormatUint(uint64(version), 10))
+}
+
+// Parse reads the version out of an If-Match header, "*" matches
+// any version and is returned as zero.
+func Parse(ifMatch string) (uint, error) {
+	ifMatch = strings.TrimSpace(ifMatch)
+	if ifMatch == "" {
+		return 0, ErrPreconditionRequired
+	}
+
+	if ifMatch == "*" {
+		return 0, nil
+	}
+
+	unquoted, err := strconv.Unquote(strings.TrimPrefix(ifMatch, "W/"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid If-Match header %s", ifMatch)
+	}
+
+	version, err := strconv.ParseUint(unquoted, 10, 32)
+	if err != nil || version == 0 {
+		return 0, fmt.Errorf("invalid If-Match header %s", ifMatch)
+	}
+
+	return uint(version), nil
+}
+
+// IfMatch reads the expected version of a write request, the request is
+// answered with 428 or 400 when the header is missing or malformed.
+func IfMatch(c *gin.Context) (uint, bool) {
+	version, err := Parse(c.GetHeader("If-Match"))
+	if errors.Is(err, ErrPreconditionRequired) {
+		response.Error(c, http.StatusPreconditionRequired, err)
+		return 0, false
+	}
+	if err != nil {
+		response.ErrorWithMessage(c, http.StatusBadRequest, "invalid parameters", err)
+		return 0, false
+	}
+
+	return version, true
+}
@@ -0,0 +1,104 @@
+package locale
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var tagPattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// Normalize lowercases a language tag like en_US into en-us,
+// it returns an empty string when the tag is not valid.
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if !tagPattern.MatchString(tag) {
+		return ""
+	}
+	return tag
+}
+
+// Base returns the primary language of a tag, en for en-us.
+func Base(tag string) string {
+	if i := strings.Index(tag, "-"); i > 0 {
+		return tag[:i]
+	}
+	return tag
+}
+
+// ParseAcceptLanguage returns the languages of an Accept-Language header,
+// most preferred first, languages with a zero quality are dropped.
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var langs []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := Normalize(fields[0])
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, v := range fields[1:] {
+			v = strings.TrimSpace(v)
+			if strings.HasPrefix(v, "q=") {
+				if parsed, err := strconv.ParseFloat(v[2:], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		langs = append(langs, weighted{tag, q})
+	}
+
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+
+	res := make([]string, 0, len(langs))
+	for _, v := range langs {
+		res = append(res, v.tag)
+	}
+	return res
+}
+
+// Preferred returns the languages asked by a request, the lang query
+// parameter wins over the Accept-Language header.
+func Preferred(c *gin.Context) []string {
+	var res []string
+	if lang := Normalize(c.Query("lang")); lang != "" {
+		res = append(res, lang)
+	}
+	return append(res, ParseAcceptLanguage(c.GetHeader("Accept-Language"))...)
+}
+
+// Chain lists the languages to try in order, every preferred language
+// is followed by its primary language and the fallback comes last.
+func Chain(preferred []string, fallback []string) []string {
+	var res []string
+	seen := map[string]bool{}
+	add := func(tag string) {
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			res = append(res, tag)
+		}
+	}
+
+	for _, v := range preferred {
+		add(v)
+		add(Base(v))
+	}
+	for _, v := range fallback {
+		add(Normalize(v))
+	}
+
+	return res
+}
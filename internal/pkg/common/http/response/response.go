@@ -36,3 +36,22 @@ func SuccessWithMessage(c *gin.Context, httpCode int, msg string, data interface
 		"data":       data,
 	})
 }
+
+func SuccessWithMeta(c *gin.Context, httpCode int, data interface{}, meta interface{}) {
+	c.JSON(httpCode, gin.H{
+		"success":    true,
+		"statusCode": httpCode,
+		"message":    "success",
+		"data":       data,
+		"meta":       meta,
+	})
+}
+
+func ErrorWithData(c *gin.Context, httpCode int, err error, data interface{}) {
+	c.JSON(httpCode, gin.H{
+		"success":    false,
+		"statusCode": httpCode,
+		"message":    err.Error(),
+		"data":       data,
+	})
+}
@@ -0,0 +1,176 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Pagination holds the paging parameters shared by every listing endpoint.
+// Page based and cursor based paging are mutually exclusive, when a cursor
+// is given the page is ignored.
+type Pagination struct {
+	Page   int    `form:"page"`
+	Limit  int    `form:"limit"`
+	Cursor string `form:"cursor"`
+}
+
+// Cursor is the decoded form of the opaque cursor query parameter.
+// After and Before are primary keys, rows are read after or before them.
+type Cursor struct {
+	After  uint `json:"a,omitempty"`
+	Before uint `json:"b,omitempty"`
+}
+
+type Meta struct {
+	Total      int64  `json:"total"`
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	Next       string `json:"next,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// Normalize fills in default values and validates the parameters.
+func (p *Pagination) Normalize() error {
+	if p.Limit <= 0 {
+		p.Limit = DefaultLimit
+	}
+	if p.Limit > MaxLimit {
+		p.Limit = MaxLimit
+	}
+
+	if p.Cursor != "" {
+		p.Page = 0
+		if _, err := DecodeCursor(p.Cursor); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	if p.Page <= 0 {
+		p.Page = 1
+	}
+
+	return nil
+}
+
+func (p Pagination) Offset() int {
+	if p.Page <= 1 {
+		return 0
+	}
+	return (p.Page - 1) * p.Limit
+}
+
+func EncodeCursor(cursor Cursor) string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeCursor(str string) (cursor Cursor, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return cursor, fmt.Errorf("invalid cursor")
+	}
+
+	if err := json.Unmarshal(b, &cursor); err != nil {
+		return cursor, fmt.Errorf("invalid cursor")
+	}
+
+	if cursor.After == 0 && cursor.Before == 0 {
+		return cursor, fmt.Errorf("invalid cursor")
+	}
+
+	return cursor, nil
+}
+
+// Scope applies limit, offset or cursor conditions on the query.
+// Nothing is applied when no limit is set, so callers that do not
+// paginate keep receiving every row.
+func Scope(p Pagination, column string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if p.Limit <= 0 {
+			return db
+		}
+
+		if p.Cursor != "" {
+			cursor, _ := DecodeCursor(p.Cursor)
+			if cursor.Before != 0 {
+				return db.Where(column+" < ?", cursor.Before).Order(column + " desc").Limit(p.Limit)
+			}
+			return db.Where(column+" > ?", cursor.After).Order(column).Limit(p.Limit)
+		}
+
+		return db.Order(column).Offset(p.Offset()).Limit(p.Limit)
+	}
+}
+
+// IsBackward reports whether rows were read in reverse order
+// and must be reversed by the caller before returning them.
+func (p Pagination) IsBackward() bool {
+	if p.Cursor == "" {
+		return false
+	}
+	cursor, _ := DecodeCursor(p.Cursor)
+	return cursor.Before != 0
+}
+
+// NewMeta builds the pagination meta of a listing, next and prev links
+// are derived from the request url so every other filter is preserved.
+// firstID and lastID are the primary keys of the first and last row of the page.
+func NewMeta(u *url.URL, p Pagination, total int64, count int, firstID, lastID uint) Meta {
+	meta := Meta{
+		Total: total,
+		Page:  p.Page,
+		Limit: p.Limit,
+	}
+
+	var hasNext, hasPrev bool
+	if p.Cursor != "" {
+		backward := p.IsBackward()
+		hasNext = count > 0 && (count == p.Limit || backward)
+		hasPrev = count > 0 && (count == p.Limit || !backward)
+	} else {
+		hasNext = int64(p.Page*p.Limit) < total
+		hasPrev = p.Page > 1
+	}
+
+	if hasNext {
+		meta.NextCursor = EncodeCursor(Cursor{After: lastID})
+		meta.Next = link(u, "page", strconv.Itoa(p.Page+1))
+		if p.Cursor != "" {
+			meta.Next = link(u, "cursor", meta.NextCursor)
+		}
+	}
+
+	if hasPrev && count > 0 {
+		meta.PrevCursor = EncodeCursor(Cursor{Before: firstID})
+	}
+	if hasPrev {
+		meta.Prev = link(u, "page", strconv.Itoa(p.Page-1))
+		if p.Cursor != "" {
+			meta.Prev = link(u, "cursor", meta.PrevCursor)
+		}
+	}
+
+	return meta
+}
+
+func link(u *url.URL, key, val string) string {
+	query := u.Query()
+	query.Del("page")
+	query.Del("cursor")
+	query.Set(key, val)
+
+	return (&url.URL{Path: u.Path, RawQuery: query.Encode()}).String()
+}
@@ -0,0 +1,55 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+const (
+	// MergePatch is a RFC 7396 JSON Merge Patch, the default when no media type is given.
+	MergePatch = "application/merge-patch+json"
+	// JSONPatch is a RFC 6902 JSON Patch.
+	JSONPatch = "application/json-patch+json"
+)
+
+var (
+	ErrUnsupportedMediaType = errors.New("unsupported patch media type")
+	ErrInvalidPatch         = errors.New("invalid patch")
+)
+
+// Apply applies a patch on a json document according to the media type of the patch.
+func Apply(contentType string, doc []byte, patch []byte) ([]byte, error) {
+	mediaType := MergePatch
+	if contentType != "" {
+		var err error
+		mediaType, _, err = mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, contentType)
+		}
+	}
+
+	switch mediaType {
+	// plain json is accepted as a merge patch
+	case MergePatch, "application/json":
+		res, err := jsonpatch.MergePatch(doc, patch)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err.Error())
+		}
+		return res, nil
+	case JSONPatch:
+		ops, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err.Error())
+		}
+		res, err := ops.Apply(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err.Error())
+		}
+		return res, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, mediaType)
+}
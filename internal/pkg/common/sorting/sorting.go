@@ -0,0 +1,86 @@
+package sorting
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Field is a single sort term, a leading "-" in the query parameter
+// means descending order.
+type Field struct {
+	Column string
+	Desc   bool
+}
+
+type Sort []Field
+
+// Parse reads a sort parameter such as "-publish_at,title" and checks every
+// field against allowed, a map of public field name to column name.
+func Parse(str string, allowed map[string]string) (res Sort, err error) {
+	if strings.TrimSpace(str) == "" {
+		return res, nil
+	}
+
+	seen := map[string]bool{}
+	for _, v := range strings.Split(str, ",") {
+		name := strings.TrimSpace(v)
+		desc := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(strings.TrimPrefix(name, "-"), "+")
+
+		column, ok := allowed[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown sort field %q, allowed fields are %s", name, strings.Join(fields(allowed), ", "))
+		}
+
+		if seen[column] {
+			return nil, fmt.Errorf("duplicate sort field %q", name)
+		}
+		seen[column] = true
+
+		res = append(res, Field{Column: column, Desc: desc})
+	}
+
+	return res, nil
+}
+
+// String returns the normalized form of the sort parameter.
+func (s Sort) String() string {
+	res := make([]string, 0, len(s))
+	for _, v := range s {
+		if v.Desc {
+			res = append(res, "-"+v.Column)
+			continue
+		}
+		res = append(res, v.Column)
+	}
+	return strings.Join(res, ",")
+}
+
+// Scope applies the order by clause of a sort parameter, the parameter
+// must be validated with Parse beforehand, invalid fields are ignored.
+func Scope(str string, allowed map[string]string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		res, err := Parse(str, allowed)
+		if err != nil {
+			return db
+		}
+
+		for _, v := range res {
+			db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: v.Column}, Desc: v.Desc})
+		}
+		return db
+	}
+}
+
+func fields(allowed map[string]string) []string {
+	res := make([]string, 0, len(allowed))
+	for k := range allowed {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
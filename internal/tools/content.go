@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+
+	wordsPerMinute = 200
+)
+
+var (
+	markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+	// sanitizer only keeps the tags and attributes safe in user generated content
+	sanitizer = bluemonday.UGCPolicy()
+	stripper  = bluemonday.StrictPolicy()
+)
+
+// RenderContent turns a content written in the given format into sanitized html,
+// an empty format is plain text.
+func RenderContent(format string, content string) (string, error) {
+	switch format {
+	case FormatPlain, "":
+		var b strings.Builder
+		for _, v := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n\n") {
+			if v = strings.TrimSpace(v); v != "" {
+				b.WriteString("<p>" + strings.ReplaceAll(html.EscapeString(v), "\n", "<br>") + "</p>\n")
+			}
+		}
+		return b.String(), nil
+	case FormatMarkdown:
+		var b bytes.Buffer
+		if err := markdown.Convert([]byte(content), &b); err != nil {
+			return "", err
+		}
+		return SanitizeHTML(b.String()), nil
+	case FormatHTML:
+		return SanitizeHTML(content), nil
+	}
+
+	return "", fmt.Errorf("unknown content format %q, allowed formats are %s, %s, %s", format, FormatPlain, FormatMarkdown, FormatHTML)
+}
+
+// SanitizeHTML removes every tag and attribute that is not allowlisted.
+func SanitizeHTML(str string) string {
+	return sanitizer.Sanitize(str)
+}
+
+// PlainText strips the tags of a html text and collapses its whitespace.
+func PlainText(str string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(stripper.Sanitize(str))), " ")
+}
+
+// Excerpt cuts a plain text at a word boundary so it fits in max characters.
+func Excerpt(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+
+	cut := []rune(text)[:max]
+	if i := strings.LastIndex(string(cut), " "); i > 0 {
+		return strings.TrimRight(string(cut)[:i], ",.;:") + "…"
+	}
+	return string(cut) + "…"
+}
+
+// ReadingTime estimates the minutes needed to read a number of words.
+func ReadingTime(words int) int {
+	return int(math.Ceil(float64(words) / wordsPerMinute))
+}
@@ -1,6 +1,10 @@
 package tools
 
-import "strconv"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 func StringsToInt(str string) int {
 	i, _ := strconv.Atoi(str)
@@ -10,3 +14,21 @@ func StringsToInt(str string) int {
 func IntToString(i int) string {
 	return strconv.Itoa(i)
 }
+
+// StringsToUints parses a comma separated list of ids, empty items are skipped.
+func StringsToUints(str string) ([]uint, error) {
+	var res []uint
+	for _, v := range strings.Split(str, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		i, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || i == 0 {
+			return nil, fmt.Errorf("invalid id %q", v)
+		}
+		res = append(res, uint(i))
+	}
+	return res, nil
+}
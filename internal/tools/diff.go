@@ -0,0 +1,95 @@
+package tools
+
+import "strings"
+
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// diffMaxCells bounds the table of the longest common subsequence, 4M cells
+// take 16 MB, texts differing over more words are replaced as a whole.
+const diffMaxCells = 4 << 20
+
+type DiffChunk struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// DiffWords computes a word level diff between two texts,
+// consecutive words with the same operation are merged into one chunk.
+func DiffWords(from, to string) []DiffChunk {
+	a, b := strings.Fields(from), strings.Fields(to)
+
+	// skip common prefix and suffix, most edits touch a small part of the text
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var res []DiffChunk
+	res = appendChunk(res, DiffEqual, a[:prefix]...)
+
+	// longest common subsequence of the remaining words
+	x, y := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(x)*len(y) > diffMaxCells {
+		res = appendChunk(res, DiffDelete, x...)
+		res = appendChunk(res, DiffInsert, y...)
+		return appendChunk(res, DiffEqual, a[len(a)-suffix:]...)
+	}
+
+	lcs := make([][]int32, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			res = appendChunk(res, DiffEqual, x[i])
+			i, j = i+1, j+1
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			res = appendChunk(res, DiffDelete, x[i])
+			i++
+		default:
+			res = appendChunk(res, DiffInsert, y[j])
+			j++
+		}
+	}
+	res = appendChunk(res, DiffDelete, x[i:]...)
+	res = appendChunk(res, DiffInsert, y[j:]...)
+
+	res = appendChunk(res, DiffEqual, a[len(a)-suffix:]...)
+	return res
+}
+
+func appendChunk(res []DiffChunk, op string, words ...string) []DiffChunk {
+	if len(words) == 0 {
+		return res
+	}
+
+	text := strings.Join(words, " ")
+	if len(res) > 0 && res[len(res)-1].Op == op {
+		res[len(res)-1].Text += " " + text
+		return res
+	}
+
+	return append(res, DiffChunk{Op: op, Text: text})
+}
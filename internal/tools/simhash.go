@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// shingleSize is the number of consecutive words hashed together, it keeps
+// word order in the fingerprint so shuffled texts do not look alike.
+const shingleSize = 3
+
+// SimHash fingerprints a text so near duplicates differ in only a few bits,
+// words are lowercased and stripped of punctuation first.
+func SimHash(text string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) == 0 {
+		return 0
+	}
+
+	n := shingleSize
+	if len(words) < n {
+		n = len(words)
+	}
+
+	var weights [64]int
+	for i := 0; i+n <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+n], " ")))
+		sum := h.Sum64()
+		for b := 0; b < 64; b++ {
+			if sum&(1<<uint(b)) != 0 {
+				weights[b]++
+			} else {
+				weights[b]--
+			}
+		}
+	}
+
+	var res uint64
+	for b, w := range weights {
+		if w > 0 {
+			res |= 1 << uint(b)
+		}
+	}
+	return res
+}
+
+// Similarity compares two fingerprints, 1 is identical and 0 has every bit different.
+func Similarity(a, b uint64) float64 {
+	return 1 - float64(bits.OnesCount64(a^b))/64
+}
+
+// Bands cuts a fingerprint into n bands of consecutive bits, two fingerprints
+// differing in fewer than n bits always have at least one band in common.
+func Bands(fingerprint uint64, n int) []uint64 {
+	res := make([]uint64, n)
+	for i := range res {
+		shift, mask := Band(i, n)
+		res[i] = (fingerprint >> shift) & mask
+	}
+	return res
+}
+
+// Band returns the shift and the mask reading band i of n from a fingerprint.
+func Band(i, n int) (shift uint, mask uint64) {
+	start, end := uint(i*64/n), uint((i+1)*64/n)
+	return start, 1<<(end-start) - 1
+}
@@ -0,0 +1,169 @@
+package persistence
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ntm/internal/domain/media"
+	"github.com/ntm/internal/infrastructure/storage"
+	"github.com/ntm/internal/tools"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// png is the smallest header http.DetectContentType sniffs as a png image.
+var png = []byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR")
+
+type MediaRepoTestSuite struct {
+	suite.Suite
+	mock sqlmock.Sqlmock
+	repo media.Repository
+	dir  string
+}
+
+func (suite *MediaRepoTestSuite) SetupTest() {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		log.Fatalf("an error '%v' was not expected when opening a stub database connection", err)
+	}
+
+	gdb, err1 := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	suite.Equal(nil, err1)
+
+	repo := media.NewRepository(gdb)
+	suite.mock = mock
+	suite.repo = repo
+	suite.dir = suite.T().TempDir()
+}
+
+func (suite *MediaRepoTestSuite) TestUploadMediaSuite() {
+	const sql = `INSERT INTO "media" \("key","url","file_name","mime_type","size","caption","credit","alt_text"\) VALUES (.+) RETURNING`
+
+	suite.mock.ExpectBegin()
+	suite.mock.
+		ExpectQuery(sql).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "cover.png", "image/png", len(png), "market open", "Setia Budi", "trading floor").
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "id"}).AddRow(time.Now(), time.Now(), 1))
+	suite.mock.ExpectCommit()
+
+	usecase := media.NewUseCase(suite.repo, storage.NewStorageLocal(suite.dir, "/files"))
+	dto := media.MediaDTO{Caption: "market open", Credit: "Setia Budi", AltText: "trading floor"}
+	res, err := usecase.Upload(context.Background(), bytes.NewReader(png), "../cover.png", int64(len(png)), dto)
+	suite.Empty(err)
+	suite.Equal(uint(1), res.ID)
+	suite.True(strings.HasSuffix(res.Key, ".png"))
+	suite.Equal("/files/"+res.Key, res.URL)
+
+	stored, err := os.ReadFile(filepath.Join(suite.dir, res.Key))
+	suite.Empty(err)
+	suite.Equal(png, stored)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *MediaRepoTestSuite) TestRejectUploadMediaSuite() {
+	usecase := media.NewUseCase(suite.repo, storage.NewStorageLocal(suite.dir, "/files"))
+
+	// the type is sniffed, the name does not matter
+	text := []byte("#!/bin/sh\necho hello")
+	_, err := usecase.Upload(context.Background(), bytes.NewReader(text), "photo.jpg", int64(len(text)), media.MediaDTO{})
+	suite.ErrorIs(err, media.ErrUnsupportedType)
+
+	_, err = usecase.Upload(context.Background(), bytes.NewReader(png), "big.png", media.MaxSize+1, media.MediaDTO{})
+	suite.ErrorIs(err, media.ErrTooLarge)
+
+	entries, _ := os.ReadDir(suite.dir)
+	suite.Empty(entries)
+}
+
+func (suite *MediaRepoTestSuite) TestStorageLocalSuite() {
+	store := storage.NewStorageLocal(suite.dir, "/files/")
+	suite.Equal("/files/media/a.png", store.URL("media/a.png"))
+
+	suite.Empty(store.Put(context.Background(), "media/a.png", bytes.NewReader(png), int64(len(png)), "image/png"))
+	suite.FileExists(filepath.Join(suite.dir, "media", "a.png"))
+
+	suite.Empty(store.Delete(context.Background(), "media/a.png"))
+	suite.NoFileExists(filepath.Join(suite.dir, "media", "a.png"))
+	suite.Empty(store.Delete(context.Background(), "media/a.png"))
+
+	suite.Error(store.Put(context.Background(), "../escape.png", bytes.NewReader(png), int64(len(png)), "image/png"))
+}
+
+func (suite *MediaRepoTestSuite) TestStorageS3Suite() {
+	// a local stand-in for an S3 compatible service
+	objects := map[string][]byte{}
+	types := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			if r.Header.Get("X-Amz-Content-Sha256") == "STREAMING-AWS4-HMAC-SHA256-PAYLOAD" {
+				body = decodeAWSChunked(body)
+			}
+			objects[r.URL.Path], types[r.URL.Path] = body, r.Header.Get("Content-Type")
+			w.Header().Set("ETag", `"etag"`)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+	defer server.Close()
+
+	client, err := tools.S3Client(tools.S3Config{
+		Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+		Region:    "us-east-1",
+		AccessKey: "minio",
+		SecretKey: "minio123",
+	})
+	suite.Empty(err)
+
+	store := storage.NewStorageS3(client, "news", "")
+	suite.Equal(server.URL+"/news/media/a.png", store.URL("media/a.png"))
+
+	suite.Empty(store.Put(context.Background(), "media/a.png", bytes.NewReader(png), int64(len(png)), "image/png"))
+	suite.Equal(png, objects["/news/media/a.png"])
+	suite.Equal("image/png", types["/news/media/a.png"])
+
+	suite.Empty(store.Delete(context.Background(), "media/a.png"))
+	suite.Empty(objects)
+}
+
+// decodeAWSChunked strips the chunk headers of a signed streaming upload.
+func decodeAWSChunked(body []byte) []byte {
+	var res []byte
+	for len(body) > 0 {
+		i := bytes.Index(body, []byte("\r\n"))
+		if i < 0 {
+			break
+		}
+		size, err := strconv.ParseInt(strings.SplitN(string(body[:i]), ";", 2)[0], 16, 64)
+		if err != nil || size == 0 {
+			break
+		}
+		body = body[i+2:]
+		res = append(res, body[:size]...)
+		body = body[size+2:]
+	}
+	return res
+}
+
+func TestMediaRepoTestSuite(t *testing.T) {
+	suite.Run(t, new(MediaRepoTestSuite))
+}
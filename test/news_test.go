@@ -2,12 +2,20 @@ package persistence
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/ntm/internal/domain/news"
+	"github.com/ntm/internal/domain/tag"
+	"github.com/ntm/internal/pkg/common/http/locale"
+	"github.com/ntm/internal/pkg/common/pagination"
+	"github.com/ntm/internal/pkg/common/patch"
+	"github.com/ntm/internal/tools"
 	"github.com/stretchr/testify/suite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -40,7 +48,7 @@ func (suite *NewsRepoTestSuite) TestSaveNewsSuite() {
 
 	suite.mock.ExpectBegin()
 	suite.mock.
-		ExpectQuery(sql).WithArgs(news.Title, news.Writer, news.Content, news.Status, news.TopicID).
+		ExpectQuery(sql).WithArgs(news.Title, news.Writer, news.Content, news.Status, news.TopicID, 1, 0, 0).
 		WillReturnRows(sqlmock.NewRows([]string{"publish_at", "created_at", "updated_at", "deleted_at", "id"}).AddRow(nil, time.Now(), time.Now(), time.Now(), id))
 	suite.mock.ExpectCommit()
 
@@ -55,6 +63,669 @@ func (suite *NewsRepoTestSuite) TestSaveNewsSuite() {
 		suite.T().Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func (suite *NewsRepoTestSuite) TestSearchNewsSuite() {
+	const sql = `SELECT news\.\*, ts_rank\(.+websearch_to_tsquery\('simple', \$1\)\) as search_rank, .+ as snippet FROM "news" ` +
+		`WHERE \(.+\) @@ websearch_to_tsquery\('simple', \$4\) AND status = \$5 AND "news"."deleted_at" IS NULL ORDER BY search_rank desc`
+
+	suite.mock.
+		ExpectQuery(sql).
+		WithArgs("mutual fund", "mutual fund", "mutual fund", "mutual fund", "publish").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "search_rank", "snippet"}))
+
+	ctx := context.WithValue(context.Background(), news.ContextKey("news_filter"), news.Filter{Q: "mutual fund", Status: "publish"})
+	_, err := suite.repo.GetAll(ctx)
+	suite.Empty(err)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestPublishScheduledNewsSuite() {
+	now := time.Now()
+	const sql = `UPDATE news SET status = \$1, updated_at = \$2, version = version \+ 1 WHERE status = \$3 AND publish_at <= \$4 AND deleted_at IS NULL RETURNING id`
+
+	suite.mock.
+		ExpectQuery(sql).
+		WithArgs(news.StatusPublish, now, news.StatusScheduled, now).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(5))
+
+	ids, err := suite.repo.PublishScheduled(context.Background(), now)
+	suite.Empty(err)
+	suite.Equal([]uint{3, 5}, ids)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestUnpublishExpiredNewsSuite() {
+	now := time.Now()
+	const sql = `UPDATE news SET status = \$1, updated_at = \$2, version = version \+ 1 WHERE status = \$3 AND unpublish_at <= \$4 AND deleted_at IS NULL RETURNING id`
+
+	suite.mock.
+		ExpectQuery(sql).
+		WithArgs(news.StatusExpired, now, news.StatusPublish, now).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+
+	ids, err := suite.repo.UnpublishExpired(context.Background(), now)
+	suite.Empty(err)
+	suite.Equal([]uint{4}, ids)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestPublishPastUnpublishAtNewsSuite() {
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "news" WHERE id = \$1 AND "news"."deleted_at" IS NULL`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "unpublish_at"}).AddRow(2, "approved", time.Now().Add(-time.Hour)))
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "news_media" WHERE "news_media"."news_id" = \$1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"news_id", "media_id"}))
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "news_tags" WHERE "news_tags"."news_id" = \$1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"news_id", "tag_id"}))
+
+	usecase := news.NewUseCase(suite.repo)
+	_, err := usecase.Transition(context.Background(), 2, news.StatusPublish, "", nil)
+	suite.EqualError(err, "unpublish_at must be in the future and after publish_at")
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestSaveRejectWithoutReasonNewsSuite() {
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "news" WHERE id = \$1 AND "news"."deleted_at" IS NULL`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "version"}).AddRow(2, "in_review", 3))
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "news_media" WHERE "news_media"."news_id" = \$1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"news_id", "media_id"}))
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "news_tags" WHERE "news_tags"."news_id" = \$1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"news_id", "tag_id"}))
+
+	// a plain update cannot skip the reason the reject transition asks for
+	usecase := news.NewUseCase(suite.repo)
+	_, err := usecase.Save(context.Background(), news.NewsDTO{ID: 2, Title: "mutual fund", Status: string(news.StatusDraft)})
+	suite.ErrorIs(err, news.ErrReasonRequired)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestSaveInvalidKeepsTagNamesNewsSuite() {
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "tags" WHERE tag_key = \$1 OR id IN \(SELECT tag_id FROM tag_aliases WHERE alias_key = \$2\)`).
+		WithArgs("crypto", "crypto").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "topics" WHERE "topics"."id" = \$1`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	// the missing tag is not created for a news that is not saved
+	usecase := news.NewUseCase(suite.repo)
+	_, err := usecase.Save(context.Background(), news.NewsDTO{Title: "bitcoin halving", Content: "bitcoin halving", TagNames: []string{" Crypto"}, TopicID: 5})
+	suite.ErrorIs(err, gorm.ErrRecordNotFound)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestPatchWithoutTitleNewsSuite() {
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "news" WHERE id = \$1 AND "news"."deleted_at" IS NULL`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "content", "status", "slug"}).AddRow(1, "mutual fund", "mutual fund is safe", news.StatusDraft, "mutual-fund"))
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "news_media" WHERE "news_media"."news_id" = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"news_id", "media_id"}))
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "news_tags" WHERE "news_tags"."news_id" = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"news_id", "tag_id"}))
+
+	// the merged news is validated before anything is saved
+	usecase := news.NewUseCase(suite.repo)
+	_, err := usecase.Patch(context.Background(), 1, 0, patch.MergePatch, []byte(`{"title": null}`))
+	suite.ErrorIs(err, patch.ErrInvalidPatch)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestCreatePublishedNewsSuite() {
+	usecase := news.NewUseCase(suite.repo)
+	_, err := usecase.Save(context.Background(), news.NewsDTO{Title: "mutual fund", Content: "mutual fund is safe", Status: string(news.StatusPublish)})
+	suite.ErrorIs(err, news.ErrCreateStatus)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestAddRevisionSuite() {
+	revision := news.Revision{NewsID: 1, Title: "mutual fund", Content: "mutual fund is safe", Status: "draft", TagIDs: news.UintList{1, 2}, TopicID: 1, Author: "budi"}
+	const sql = `INSERT INTO "revisions" (.+) RETURNING`
+
+	suite.mock.ExpectBegin()
+	suite.mock.
+		ExpectQuery(sql).WithArgs(revision.NewsID, revision.Title, revision.Content, revision.Status, "[1,2]", revision.TopicID, revision.Author).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "id"}).AddRow(time.Now(), 1))
+	suite.mock.ExpectCommit()
+
+	revision, err := suite.repo.AddRevision(context.Background(), revision)
+	suite.Empty(err)
+	suite.Equal(uint(1), revision.ID)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestDiffRevisionSuite() {
+	diff := tools.DiffWords("mutual fund is a safe investment", "mutual fund is the safest investment type")
+	suite.Equal([]tools.DiffChunk{
+		{Op: tools.DiffEqual, Text: "mutual fund is"},
+		{Op: tools.DiffDelete, Text: "a safe"},
+		{Op: tools.DiffInsert, Text: "the safest"},
+		{Op: tools.DiffEqual, Text: "investment"},
+		{Op: tools.DiffInsert, Text: "type"},
+	}, diff)
+}
+
+func (suite *NewsRepoTestSuite) TestDiffRevisionLimitSuite() {
+	var from, to []string
+	for i := 0; i < 3000; i++ {
+		from = append(from, fmt.Sprintf("a%d", i))
+		to = append(to, fmt.Sprintf("b%d", i))
+	}
+
+	// unrelated long texts are replaced as a whole instead of filling a huge table
+	diff := tools.DiffWords("intro "+strings.Join(from, " ")+" outro", "intro "+strings.Join(to, " ")+" outro")
+	suite.Equal([]tools.DiffChunk{
+		{Op: tools.DiffEqual, Text: "intro"},
+		{Op: tools.DiffDelete, Text: strings.Join(from, " ")},
+		{Op: tools.DiffInsert, Text: strings.Join(to, " ")},
+		{Op: tools.DiffEqual, Text: "outro"},
+	}, diff)
+}
+
+func (suite *NewsRepoTestSuite) TestUpdateStatusNewsSuite() {
+	const sql = `UPDATE "news" SET "publish_at"=\$1,"status"=\$2,"updated_at"=\$3,"version"=version \+ 1 WHERE \(id = \$4 and status = \$5\) AND "news"."deleted_at" IS NULL`
+	publishAt := time.Now()
+
+	suite.mock.ExpectBegin()
+	suite.mock.
+		ExpectExec(sql).
+		WithArgs(publishAt, news.StatusApproved, sqlmock.AnyArg(), 1, news.StatusInReview).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectCommit()
+
+	ok, err := suite.repo.UpdateStatus(context.Background(), 1, news.StatusInReview, news.StatusApproved, publishAt)
+	suite.Empty(err)
+	suite.False(ok)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestTransitionRollbackNewsSuite() {
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "news" WHERE id = \$1 AND "news"."deleted_at" IS NULL`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "status"}).AddRow(1, "mutual fund", news.StatusDraft))
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "news_media" WHERE "news_media"."news_id" = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"news_id", "media_id"}))
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "news_tags" WHERE "news_tags"."news_id" = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"news_id", "tag_id"}))
+
+	// the status change is rolled back when its transition cannot be recorded
+	suite.mock.ExpectBegin()
+	suite.mock.
+		ExpectExec(`UPDATE "news" SET .+ WHERE \(id = \$4 and status = \$5\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.
+		ExpectQuery(`INSERT INTO "transitions" (.+) RETURNING`).
+		WillReturnError(fmt.Errorf("connection reset"))
+	suite.mock.ExpectRollback()
+
+	usecase := news.NewUseCase(suite.repo)
+	_, err := usecase.Transition(context.Background(), 1, news.StatusInReview, "", nil)
+	suite.EqualError(err, "connection reset")
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestCanTransitionNewsSuite() {
+	suite.True(news.CanTransition(news.StatusDraft, news.StatusInReview))
+	suite.True(news.CanTransition(news.StatusInReview, news.StatusDraft))
+	suite.True(news.CanTransition(news.StatusApproved, news.StatusPublish))
+	suite.False(news.CanTransition(news.StatusDraft, news.StatusPublish))
+	suite.False(news.CanTransition(news.StatusArchived, news.StatusPublish))
+	suite.False(news.CanTransition(news.StatusPublish, news.StatusExpired))
+	suite.True(news.CanTransition(news.StatusExpired, news.StatusDraft))
+}
+
+func (suite *NewsRepoTestSuite) TestCountByTagsNewsSuite() {
+	const tagged = `\(EXISTS \(SELECT 1 FROM news_tags JOIN tags ON tags.id = news_tags.tag_id WHERE news_tags.news_id = news.id AND `
+	const sql = `SELECT count\(\*\) FROM "news" WHERE ` +
+		tagged + `tags.id = \$1\)\) AND ` + tagged + `tags.id = \$2\)\) AND ` + tagged + `\(tags.tag_key = \$3 OR tags.id IN \(SELECT tag_id FROM tag_aliases WHERE alias_key = \$4\)\)\)\) AND ` +
+		`\(NOT EXISTS \(SELECT 1 FROM news_tags WHERE news_tags.news_id = news.id AND news_tags.tag_id in \(\$5\)\)\) AND "news"."deleted_at" IS NULL`
+
+	suite.mock.
+		ExpectQuery(sql).
+		WithArgs(1, 2, "stock", "stock", 9).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	filter := news.Filter{Tags: "1, 2", TagNames: " Stock", TagMatch: news.TagMatchAll, ExcludeTags: "9"}
+	suite.Empty(filter.ParseTags())
+
+	ctx := context.WithValue(context.Background(), news.ContextKey("news_filter"), filter)
+	total, err := suite.repo.Count(ctx)
+	suite.Empty(err)
+	suite.Equal(int64(2), total)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestParseTagsNewsSuite() {
+	filter := news.Filter{Tags: "3,4", TagNames: "crypto, Stock  Market"}
+	suite.Empty(filter.ParseTags())
+	suite.Equal(news.TagMatchAny, filter.TagMatch)
+	suite.Equal([]uint{3, 4}, filter.TagIDs)
+	suite.Equal([]string{"crypto", "stock market"}, filter.TagNameList)
+
+	filter = news.Filter{Tags: "3,abc"}
+	suite.EqualError(filter.ParseTags(), `tags: invalid id "abc"`)
+
+	filter = news.Filter{TagMatch: "some"}
+	suite.Error(filter.ParseTags())
+}
+
+func (suite *NewsRepoTestSuite) TestGetTrashNewsSuite() {
+	const sql = `SELECT \* FROM "news" WHERE deleted_at is not null ORDER BY "deleted_at" DESC,id LIMIT 20`
+
+	suite.mock.
+		ExpectQuery(sql).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}))
+
+	filter := news.Filter{Trashed: true, Sort: "-deleted_at", Pagination: pagination.Pagination{Page: 1, Limit: 20}}
+	ctx := context.WithValue(context.Background(), news.ContextKey("news_filter"), filter)
+	_, err := suite.repo.GetAll(ctx)
+	suite.Empty(err)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestPurgeNewsSuite() {
+	suite.mock.ExpectBegin()
+	suite.mock.
+		ExpectQuery(`SELECT "id" FROM "news" WHERE id in \(\$1,\$2\) and deleted_at is not null`).
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	suite.mock.ExpectExec(`DELETE FROM news_tags WHERE news_id in \(\$1\)`).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 3))
+	suite.mock.ExpectExec(`DELETE FROM news_media WHERE news_id in \(\$1\)`).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec(`DELETE FROM "revisions" WHERE news_id in \(\$1\)`).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 2))
+	suite.mock.ExpectExec(`DELETE FROM "transitions" WHERE news_id in \(\$1\)`).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec(`DELETE FROM "slug_aliases" WHERE news_id in \(\$1\)`).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec(`DELETE FROM "translations" WHERE news_id in \(\$1\)`).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec(`DELETE FROM "news" WHERE id in \(\$1\)`).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	purged, err := suite.repo.Purge(context.Background(), []uint{1, 2})
+	suite.Empty(err)
+	suite.Equal(int64(1), purged)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestSlugExistsNewsSuite() {
+	suite.mock.
+		ExpectQuery(`SELECT count\(\*\) FROM "news" WHERE slug = \$1 and id <> \$2`).
+		WithArgs("mutual-fund", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	suite.mock.
+		ExpectQuery(`SELECT count\(\*\) FROM "slug_aliases" WHERE slug = \$1 and news_id <> \$2`).
+		WithArgs("mutual-fund", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	exists, err := suite.repo.SlugExists(context.Background(), "mutual-fund", 1)
+	suite.Empty(err)
+	suite.True(exists)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestSlugifyNewsSuite() {
+	suite.Equal("how-to-start-investment", tools.Slugify("How to start investment?"))
+	suite.Equal("creme-brulee-and-strasse", tools.Slugify("  Crème Brûlée & Straße "))
+	suite.Equal("harga-saham-naik-10", tools.Slugify("Harga saham naik 10%!"))
+	suite.Equal("", tools.Slugify("株式"))
+	suite.Equal("novyy-god-v-moskve", tools.Slugify("Новый год в Москве"))
+	suite.Equal("kalimera-kosme", tools.Slugify("Καλημέρα κόσμε!"))
+	suite.Equal("shchuka-i-yozh", tools.Slugify("ЩУКА и ЁЖ"))
+}
+
+func (suite *NewsRepoTestSuite) TestBulkRollbackNewsSuite() {
+	suite.mock.ExpectBegin()
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "news" WHERE id = \$1 AND "news"."deleted_at" IS NULL`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	suite.mock.ExpectRollback()
+
+	dto := news.BulkDTO{Operations: []news.BulkOperation{
+		{Op: news.BulkStatus, ID: 7, Status: string(news.StatusInReview)},
+		{Op: news.BulkDelete, ID: 8},
+	}}
+	suite.Empty(dto.Validate())
+	suite.Equal(news.BulkTransaction, dto.Mode)
+
+	usecase := news.NewUseCase(suite.repo)
+	res, err := usecase.Bulk(context.Background(), dto)
+	suite.ErrorIs(err, news.ErrBulkRolledBack)
+	suite.Equal([]news.BulkResult{
+		{Op: news.BulkStatus, ID: 7, Error: "record not found"},
+		{Op: news.BulkDelete, ID: 8, Error: "not run"},
+	}, res)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestValidateBulkNewsSuite() {
+	dto := news.BulkDTO{Mode: "all", Operations: []news.BulkOperation{{Op: news.BulkDelete, ID: 1}}}
+	suite.Error(dto.Validate())
+
+	dto = news.BulkDTO{Mode: news.BulkBestEffort, Operations: []news.BulkOperation{{Op: news.BulkAddTags, ID: 1}}}
+	suite.EqualError(dto.Validate(), "operation 0: tags are required")
+
+	dto = news.BulkDTO{Operations: []news.BulkOperation{{Op: "retag", ID: 1}}}
+	suite.EqualError(dto.Validate(), `operation 0: unknown op "retag"`)
+
+	dto = news.BulkDTO{Operations: make([]news.BulkOperation, news.MaxBulkOperations+1)}
+	suite.Error(dto.Validate())
+}
+
+func (suite *NewsRepoTestSuite) TestGetRelatedNewsSuite() {
+	const sql = `SELECT news\.\*, \(\(SELECT count\(\*\) FROM news_tags WHERE news_tags.news_id = news.id AND news_tags.tag_id IN \(\$1,\$2\)\) \* \$3 ` +
+		`\+ CASE WHEN news.topic_id = \$4 THEN \$5 ELSE 0 END\) \* power\(0.5, .+ / \$6\) as related_score FROM "news" ` +
+		`WHERE \(id <> \$7 and status = \$8\) AND \(news.topic_id = \$9 OR EXISTS \(.+ IN \(\$10,\$11\)\)\) AND "news"."deleted_at" IS NULL ` +
+		`ORDER BY related_score desc, publish_at desc LIMIT 5`
+
+	suite.mock.
+		ExpectQuery(sql).
+		WithArgs(3, 4, 2, 1, 1, 30, 1, news.StatusPublish, 1, 3, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "related_score"}).AddRow(2, "stock picking", 3.8))
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "news_tags" WHERE "news_tags"."news_id" = \$1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"news_id", "tag_id"}))
+
+	source := news.News{ID: 1, TopicID: 1, Tags: []tag.Tag{{ID: 3}, {ID: 4}}}
+	res, err := suite.repo.GetRelated(context.Background(), source, 5)
+	suite.Empty(err)
+	suite.Len(res, 1)
+	suite.Equal(3.8, res[0].RelatedScore)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestGetRelatedWithoutTopicNewsSuite() {
+	const sql = `SELECT news\.\*, \(\(SELECT count\(\*\) FROM news_tags WHERE news_tags.news_id = news.id AND news_tags.tag_id IN \(\$1\)\) \* \$2\) ` +
+		`\* power\(0.5, .+ / \$3\) as related_score FROM "news" ` +
+		`WHERE \(id <> \$4 and status = \$5\) AND \(EXISTS \(.+ IN \(\$6\)\)\) AND "news"."deleted_at" IS NULL ` +
+		`ORDER BY related_score desc, publish_at desc LIMIT 5`
+
+	suite.mock.
+		ExpectQuery(sql).
+		WithArgs(3, 2, 30, 1, news.StatusPublish, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "related_score"}))
+
+	source := news.News{ID: 1, Tags: []tag.Tag{{ID: 3}}}
+	res, err := suite.repo.GetRelated(context.Background(), source, 5)
+	suite.Empty(err)
+	suite.Len(res, 0)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestRenderContentNewsSuite() {
+	res, err := tools.RenderContent(tools.FormatMarkdown, "# Mutual fund\n\nis **safe** <script>alert(1)</script>\n\n[more](javascript:alert(1))")
+	suite.Empty(err)
+	suite.Contains(res, "<h1>Mutual fund</h1>")
+	suite.Contains(res, "<strong>safe</strong>")
+	suite.NotContains(res, "script")
+	suite.NotContains(res, "javascript")
+
+	res, err = tools.RenderContent(tools.FormatHTML, `<p onclick="steal()">mutual <b>fund</b></p><iframe src="x"></iframe>`)
+	suite.Empty(err)
+	suite.Equal("<p>mutual <b>fund</b></p>", res)
+
+	res, err = tools.RenderContent(tools.FormatPlain, "a < b\nc\n\nd")
+	suite.Empty(err)
+	suite.Equal("<p>a &lt; b<br>c</p>\n<p>d</p>\n", res)
+
+	_, err = tools.RenderContent("rtf", "mutual fund")
+	suite.Error(err)
+}
+
+func (suite *NewsRepoTestSuite) TestBackfillContentNewsSuite() {
+	content := "Mutual *fund* is a safe investment type, " + strings.Repeat("word ", 400)
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "news" WHERE content <> '' and \(content_html is null or excerpt is null\) ORDER BY id`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "content", "content_format"}).AddRow(1, content, tools.FormatMarkdown))
+	suite.mock.ExpectBegin()
+	suite.mock.
+		ExpectExec(`UPDATE "news" SET "content_html"=\$1,"excerpt"=\$2,"reading_time"=\$3,"word_count"=\$4 WHERE id = \$5`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 3, 407, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "translations" WHERE content_html <> '' and excerpt is null ORDER BY id`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	usecase := news.NewUseCase(suite.repo)
+	suite.Empty(usecase.BackfillContent(context.Background()))
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestExcerptNewsSuite() {
+	text := tools.PlainText("<p>Mutual <em>fund</em> is a safe investment type, " + strings.Repeat("word ", 400) + "</p>")
+	excerpt := tools.Excerpt(text, 200)
+	suite.True(strings.HasPrefix(excerpt, "Mutual fund is a safe investment type, word"))
+	suite.True(strings.HasSuffix(excerpt, "word…"))
+	suite.Equal(3, tools.ReadingTime(407))
+
+	suite.Equal("mutual fund…", tools.Excerpt("mutual fund, stock", 14))
+}
+
+func (suite *NewsRepoTestSuite) TestFindTranslatedNewsSuite() {
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "news" WHERE id = \$1 AND "news"."deleted_at" IS NULL`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "content", "locale"}).AddRow(1, "reksa dana", "reksa dana aman", "id"))
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "news_media" WHERE "news_media"."news_id" = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"news_id", "media_id"}))
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "news_tags" WHERE "news_tags"."news_id" = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"news_id", "tag_id"}))
+	suite.mock.
+		ExpectQuery(`SELECT \* FROM "translations" WHERE news_id in \(\$1\) AND locale in \(\$2,\$3,\$4\) ORDER BY news_id, locale`).
+		WithArgs(1, "en-gb", "en", "id").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "news_id", "locale", "title", "content", "content_html", "excerpt", "word_count", "reading_time"}).
+			AddRow(1, 1, "en", "mutual fund", "mutual fund is safe", "<p>mutual fund is safe</p>", "mutual fund is safe", 4, 1))
+
+	chain := locale.Chain(locale.ParseAcceptLanguage("en-GB, fr;q=0, *;q=0.5"), []string{"id"})
+	ctx := context.WithValue(context.Background(), news.ContextKey("locales"), chain)
+
+	usecase := news.NewUseCase(suite.repo)
+	res, err := usecase.FindByID(ctx, 1)
+	suite.Empty(err)
+	suite.Equal("en", res.Locale)
+	suite.Equal("mutual fund", res.Title)
+	suite.Equal("mutual fund is safe", res.Excerpt)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestNegotiateLocaleNewsSuite() {
+	suite.Equal([]string{"en-us", "id", "en"}, locale.ParseAcceptLanguage("en;q=0.5, en_US, id;q=0.8, de;q=0"))
+	suite.Equal([]string{"pt-br", "pt", "id", "en"}, locale.Chain([]string{"pt-br"}, []string{"id", "EN", "pt"}))
+	suite.Equal("", locale.Normalize("english!"))
+}
+
+func (suite *NewsRepoTestSuite) TestSaveTranslationSuite() {
+	const sql = `INSERT INTO "translations" (.+) VALUES (.+) ON CONFLICT \("news_id","locale"\) DO UPDATE SET "title"="excluded"."title",.+ RETURNING`
+
+	suite.mock.ExpectBegin()
+	suite.mock.
+		ExpectQuery(sql).
+		WithArgs(1, "en", "mutual fund", "mutual fund is safe", 4, 1, "plain", "<p>mutual fund is safe</p>\n", "mutual fund is safe", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "id"}).AddRow(time.Now(), time.Now(), 3))
+	suite.mock.ExpectCommit()
+
+	res, err := suite.repo.UpsertTranslation(context.Background(), news.Translation{
+		NewsID:        1,
+		Locale:        "en",
+		Title:         "mutual fund",
+		Content:       "mutual fund is safe",
+		ContentFormat: tools.FormatPlain,
+		ContentHTML:   "<p>mutual fund is safe</p>\n",
+		Excerpt:       "mutual fund is safe",
+		WordCount:     4,
+		ReadingTime:   1,
+		UpdatedAt:     time.Now(),
+	})
+	suite.Empty(err)
+	suite.Equal(uint(3), res.ID)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestSimHashNewsSuite() {
+	wire := "Jakarta Composite Index closed higher on Monday as banking stocks rallied after the central bank kept its benchmark rate unchanged, traders said. " +
+		"Foreign investors were net buyers of 1.2 trillion rupiah worth of shares, led by Bank Central Asia and Bank Rakyat Indonesia."
+	pasted := "JAKARTA - " + strings.ReplaceAll(wire, "1.2", "1.3")
+	other := "Mutual funds remain a popular investment for young Indonesians who are new to the capital market, according to a survey released on Tuesday."
+
+	suite.Equal(1.0, tools.Similarity(tools.SimHash(wire), tools.SimHash(strings.ToUpper(wire))))
+	suite.GreaterOrEqual(tools.Similarity(tools.SimHash(wire), tools.SimHash(pasted)), news.DuplicateThreshold)
+	suite.Less(tools.Similarity(tools.SimHash(wire), tools.SimHash(other)), news.DuplicateThreshold)
+	suite.Equal(uint64(0), tools.SimHash(" ... "))
+}
+
+func (suite *NewsRepoTestSuite) TestFindDuplicatesNewsSuite() {
+	const sql = `SELECT "id","fingerprint" FROM "news" WHERE fingerprint <> 0 AND "news"."deleted_at" IS NULL ORDER BY id`
+
+	// 1 and 2 differ by one bit, 3 by two bits from 2 and three from 1, 4 has nothing in common
+	suite.mock.
+		ExpectQuery(sql).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "fingerprint"}).
+			AddRow(1, 0x0f0f).
+			AddRow(2, 0x0f0e).
+			AddRow(3, 0x0f08).
+			AddRow(4, -1))
+
+	usecase := news.NewUseCase(suite.repo)
+	res, err := usecase.FindDuplicates(context.Background(), 0.95)
+	suite.Empty(err)
+	suite.Equal([]news.DuplicateCluster{{IDs: []uint{1, 2, 3}, Similarity: 1 - 3.0/64}}, res)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestFindSpreadDuplicatesNewsSuite() {
+	const sql = `SELECT "id","fingerprint" FROM "news" WHERE fingerprint <> 0 AND "news"."deleted_at" IS NULL ORDER BY id`
+
+	// 1 and 2 differ by nine bits spread over the fingerprint, still within the default threshold
+	spread := int64(1<<0 | 1<<8 | 1<<16 | 1<<24 | 1<<32 | 1<<40 | 1<<48 | 1<<56 | 1<<60)
+	suite.mock.
+		ExpectQuery(sql).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "fingerprint"}).
+			AddRow(1, 0x0e).
+			AddRow(2, 0x0e^spread))
+
+	usecase := news.NewUseCase(suite.repo)
+	res, err := usecase.FindDuplicates(context.Background(), 0.85)
+	suite.Empty(err)
+	suite.Equal([]news.DuplicateCluster{{IDs: []uint{1, 2}, Similarity: 1 - 9.0/64}}, res)
+
+	suite.Equal([]uint64{0, 0xffff, 0, 0}, tools.Bands(0xffff0000, 4))
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *NewsRepoTestSuite) TestGetFingerprintsByBandsNewsSuite() {
+	sql := regexp.QuoteMeta(`SELECT "id","fingerprint" FROM "news" WHERE (fingerprint <> 0 and created_at >= $1) AND ` +
+		`(((fingerprint >> 0) & 65535) = $2 OR ((fingerprint >> 16) & 65535) = $3 OR ((fingerprint >> 32) & 65535) = $4 OR ((fingerprint >> 48) & 65535) = $5) ` +
+		`AND "news"."deleted_at" IS NULL ORDER BY id`)
+	since := time.Now().Add(-news.DuplicateWindow)
+
+	suite.mock.
+		ExpectQuery(sql).
+		WithArgs(since, 4, 3, 2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "fingerprint"}).AddRow(2, 0x0001000200030005))
+
+	res, err := suite.repo.GetFingerprintsByBands(context.Background(), since, 0x0001000200030004, 4)
+	suite.Empty(err)
+	suite.Len(res, 1)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestNewsRepoTestSuite(t *testing.T) {
 	suite.Run(t, new(NewsRepoTestSuite))
 }
@@ -0,0 +1,176 @@
+package persistence
+
+import (
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ntm/internal/domain/section"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type SectionRepoTestSuite struct {
+	suite.Suite
+	mock sqlmock.Sqlmock
+	repo section.Repository
+}
+
+func (suite *SectionRepoTestSuite) SetupTest() {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		log.Fatalf("an error '%v' was not expected when opening a stub database connection", err)
+	}
+
+	gdb, err1 := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	suite.Equal(nil, err1)
+
+	repo := section.NewRepository(gdb)
+	suite.mock = mock
+	suite.repo = repo
+}
+
+func (suite *SectionRepoTestSuite) TestGetSectionByKeySuite() {
+	rows := sqlmock.
+		NewRows([]string{"id", "key", "name", "size", "created_at"}).
+		AddRow(1, "homepage", "Homepage", 5, time.Now())
+	slots := sqlmock.
+		NewRows([]string{"id", "section_id", "live", "position", "news_id"}).
+		AddRow(3, 1, true, 1, 10).
+		AddRow(4, 1, true, 2, 11)
+
+	suite.mock.
+		ExpectQuery(`SELECT * FROM "sections" WHERE key = $1 ORDER BY "sections"."id" LIMIT 1`).
+		WithArgs("homepage").
+		WillReturnRows(rows)
+	suite.mock.
+		ExpectQuery(`SELECT * FROM "slots" WHERE live = $1 AND "slots"."section_id" = $2 ORDER BY position`).
+		WithArgs(true, 1).
+		WillReturnRows(slots)
+
+	res, err := suite.repo.GetByKey(context.Background(), "homepage")
+	suite.Empty(err)
+	suite.Equal("Homepage", res.Name)
+	suite.Len(res.Slots, 2)
+	suite.Equal(uint(11), res.Slots[1].NewsID)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *SectionRepoTestSuite) TestReplaceSectionSlotsSuite() {
+	slots := []section.Slot{
+		{SectionID: 1, Position: 1, NewsID: 10},
+		{SectionID: 1, Position: 2, NewsID: 11},
+	}
+
+	suite.mock.ExpectBegin()
+	suite.mock.
+		ExpectExec(`DELETE FROM "slots" WHERE section_id = $1 and live = $2`).
+		WithArgs(1, false).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	suite.mock.
+		ExpectQuery(`INSERT INTO "slots" ("section_id","live","position","news_id","expires_at") VALUES ($1,$2,$3,$4,$5),($6,$7,$8,$9,$10) RETURNING "id"`).
+		WithArgs(1, false, 1, 10, nil, 1, false, 2, 11, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5).AddRow(6))
+	suite.mock.ExpectCommit()
+
+	err := suite.repo.ReplaceSlots(context.Background(), 1, slots)
+	suite.Empty(err)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *SectionRepoTestSuite) TestPublishSectionSuite() {
+	now := time.Now()
+
+	suite.mock.ExpectBegin()
+	suite.mock.
+		ExpectExec(`DELETE FROM "slots" WHERE section_id = $1 and live = $2`).
+		WithArgs(1, true).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	suite.mock.
+		ExpectExec(`INSERT INTO slots (section_id, live, position, news_id, expires_at) SELECT section_id, true, position, news_id, expires_at FROM slots WHERE section_id = $1 AND live = false`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	suite.mock.
+		ExpectExec(`UPDATE "sections" SET "published_at"=$1,"updated_at"=$2 WHERE id = $3`).
+		WithArgs(now, now, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	err := suite.repo.Publish(context.Background(), 1, now)
+	suite.Empty(err)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *SectionRepoTestSuite) TestSetTooManySlotsSectionSuite() {
+	rows := sqlmock.
+		NewRows([]string{"id", "key", "name", "size", "created_at"}).
+		AddRow(1, "homepage", "Homepage", 1, time.Now())
+
+	suite.mock.
+		ExpectQuery(`SELECT * FROM "sections" WHERE "sections"."id" = $1 ORDER BY "sections"."id" LIMIT 1`).
+		WithArgs(1).
+		WillReturnRows(rows)
+	suite.mock.
+		ExpectQuery(`SELECT * FROM "slots" WHERE "slots"."section_id" = $1 ORDER BY live, position`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	usecase := section.NewUseCase(suite.repo)
+	_, err := usecase.SetSlots(context.Background(), 1, []section.Slot{{NewsID: 10}, {NewsID: 11}})
+	suite.ErrorIs(err, section.ErrInvalid)
+	suite.EqualError(err, "invalid section: section homepage only has 1 slots")
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *SectionRepoTestSuite) TestValidateSectionSuite() {
+	usecase := section.NewUseCase(suite.repo)
+
+	_, err := usecase.Add(context.Background(), section.Section{Key: "homepage", Name: "  "})
+	suite.ErrorIs(err, section.ErrInvalid)
+
+	_, err = usecase.Add(context.Background(), section.Section{Key: "homepage", Name: "Homepage", Size: section.MaxSize + 1})
+	suite.EqualError(err, "invalid section: size must be between 1 and 50")
+}
+
+func (suite *SectionRepoTestSuite) TestSetExpiredSlotSectionSuite() {
+	rows := sqlmock.
+		NewRows([]string{"id", "key", "name", "size", "created_at"}).
+		AddRow(1, "homepage", "Homepage", 5, time.Now())
+
+	suite.mock.
+		ExpectQuery(`SELECT * FROM "sections" WHERE "sections"."id" = $1 ORDER BY "sections"."id" LIMIT 1`).
+		WithArgs(1).
+		WillReturnRows(rows)
+	suite.mock.
+		ExpectQuery(`SELECT * FROM "slots" WHERE "slots"."section_id" = $1 ORDER BY live, position`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	expired := time.Now().Add(-time.Hour)
+	usecase := section.NewUseCase(suite.repo)
+	_, err := usecase.SetSlots(context.Background(), 1, []section.Slot{{NewsID: 10, ExpiresAt: &expired}})
+	suite.ErrorIs(err, section.ErrInvalid)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSectionRepoTestSuite(t *testing.T) {
+	suite.Run(t, new(SectionRepoTestSuite))
+}
@@ -9,7 +9,11 @@ import (
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgconn"
 	"github.com/ntm/internal/domain/tag"
+	"github.com/ntm/internal/pkg/common/http/etag"
+	"github.com/ntm/internal/pkg/common/pagination"
+	"github.com/ntm/internal/pkg/common/patch"
 	"github.com/stretchr/testify/suite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -37,12 +41,12 @@ func (suite *TagRepoTestSuite) SetupTest() {
 
 func (suite *TagRepoTestSuite) TestSaveTagSuite() {
 	id := uint(1)
-	tag := tag.Tag{Tag: "fund", CreatedAt: time.Now()}
-	const sql = `INSERT INTO "tags" ("tag","created_at") VALUES ($1,$2) RETURNING "created_at","updated_at","id"`
+	tag := tag.Tag{Tag: "fund", TagKey: "fund", CreatedAt: time.Now()}
+	const sql = `INSERT INTO "tags" ("tag","version","tag_key","created_at") VALUES ($1,$2,$3,$4) RETURNING "tag_key","created_at","updated_at","id"`
 
 	suite.mock.ExpectBegin()
 	suite.mock.
-		ExpectQuery(sql).WithArgs(tag.Tag, tag.CreatedAt).
+		ExpectQuery(sql).WithArgs(tag.Tag, 1, tag.TagKey, tag.CreatedAt).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(1, time.Now(), time.Now()))
 	suite.mock.ExpectCommit()
 
@@ -63,12 +67,12 @@ func (suite *TagRepoTestSuite) TestGetTagSuite() {
 		NewRows([]string{"id", "tag", "updated_at", "created_at"}).
 		AddRow(1, "fund", nil, time.Now())
 
-	const sql = `SELECT * FROM "tags" WHERE tag = $1`
-	const q = "fund"
+	const sql = `SELECT * FROM "tags" WHERE tag_key = $1 OR id IN (SELECT tag_id FROM tag_aliases WHERE alias_key = $2)`
+	const q = " Fund"
 
 	suite.mock.
 		ExpectQuery(sql).
-		WithArgs(q).
+		WithArgs("fund", "fund").
 		WillReturnRows(rows)
 
 	ctx := context.WithValue(context.Background(), tag.ContextKey("tags_filter"), tag.Filter{Tag: q})
@@ -152,6 +156,471 @@ func (suite *TagRepoTestSuite) TestGetAllTagSuite() {
 	}
 }
 
+func (suite *TagRepoTestSuite) TestGetAllTagPaginatedSuite() {
+	rows := sqlmock.
+		NewRows([]string{"id", "tag", "updated_at", "created_at"}).
+		AddRow(3, "fund", nil, time.Now()).AddRow(4, "investment", nil, time.Now())
+
+	const sql = `SELECT * FROM "tags" ORDER BY id LIMIT 2 OFFSET 2`
+
+	suite.mock.
+		ExpectQuery(sql).
+		WillReturnRows(rows)
+
+	filter := tag.Filter{Pagination: pagination.Pagination{Page: 2, Limit: 2}}
+	ctx := context.WithValue(context.Background(), tag.ContextKey("tags_filter"), filter)
+	tags, err := suite.repo.GetAll(ctx)
+	suite.Empty(err)
+	suite.Len(tags, 2)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TagRepoTestSuite) TestGetAllTagCursorSuite() {
+	rows := sqlmock.
+		NewRows([]string{"id", "tag", "updated_at", "created_at"}).
+		AddRow(4, "investment", nil, time.Now()).AddRow(3, "fund", nil, time.Now())
+
+	const sql = `SELECT * FROM "tags" WHERE id < $1 ORDER BY id desc LIMIT 2`
+
+	suite.mock.
+		ExpectQuery(sql).
+		WithArgs(5).
+		WillReturnRows(rows)
+
+	cursor := pagination.EncodeCursor(pagination.Cursor{Before: 5})
+	filter := tag.Filter{Pagination: pagination.Pagination{Limit: 2, Cursor: cursor}}
+	ctx := context.WithValue(context.Background(), tag.ContextKey("tags_filter"), filter)
+	tags, err := suite.repo.GetAll(ctx)
+	suite.Empty(err)
+	suite.Len(tags, 2)
+	suite.Equal(uint(3), tags[0].ID)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TagRepoTestSuite) TestCountTagSuite() {
+	const sql = `SELECT count(*) FROM "tags" WHERE tag_key = $1 OR id IN (SELECT tag_id FROM tag_aliases WHERE alias_key = $2)`
+	const q = "FUND"
+
+	suite.mock.
+		ExpectQuery(sql).
+		WithArgs("fund", "fund").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+
+	ctx := context.WithValue(context.Background(), tag.ContextKey("tags_filter"), tag.Filter{Tag: q})
+	total, err := suite.repo.Count(ctx)
+	suite.Empty(err)
+	suite.Equal(int64(7), total)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TagRepoTestSuite) TestPatchTagSuite() {
+	const selectSQL = `SELECT * FROM "tags" WHERE "tags"."id" = $1 ORDER BY "tags"."id" LIMIT 1`
+	const nameSQL = `SELECT * FROM "tags" WHERE tag_key = $1 OR id IN (SELECT tag_id FROM tag_aliases WHERE alias_key = $2) ORDER BY "tags"."id" LIMIT 1`
+	const aliasSQL = `DELETE FROM "tag_aliases" WHERE tag_id = $1 and alias_key = $2`
+	const updateSQL = `UPDATE "tags" SET "tag"=$1,"tag_key"=$2,"created_at"=$3,"updated_at"=$4,"version"=$5 WHERE version = $6 AND "id" = $7`
+	createdAt := time.Now()
+
+	for i := 0; i < 2; i++ {
+		suite.mock.
+			ExpectQuery(selectSQL).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "tag", "tag_key", "updated_at", "created_at", "version"}).AddRow(1, "fund", "fund", createdAt, createdAt, 1))
+	}
+	suite.mock.ExpectQuery(nameSQL).WithArgs("mutual fund", "mutual fund").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec(aliasSQL).WithArgs(1, "mutual fund").WillReturnResult(driver.RowsAffected(0))
+	suite.mock.ExpectCommit()
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec(updateSQL).WithArgs("mutual fund", "mutual fund", sqlmock.AnyArg(), sqlmock.AnyArg(), 2, 1, 1).WillReturnResult(driver.RowsAffected(1))
+	suite.mock.ExpectCommit()
+
+	usecase := tag.NewUseCase(suite.repo)
+	tag, err := usecase.Patch(context.Background(), 1, 1, patch.JSONPatch, []byte(`[{"op": "replace", "path": "/tag", "value": "mutual fund"}]`))
+	suite.Empty(err)
+	suite.Equal("mutual fund", tag.Tag)
+	suite.Equal(uint(2), tag.Version)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TagRepoTestSuite) TestStaleUpdateTagSuite() {
+	const updateSQL = `UPDATE "tags" SET "tag"=$1,"tag_key"=$2,"created_at"=$3,"updated_at"=$4,"version"=$5 WHERE version = $6 AND "id" = $7`
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec(updateSQL).WithArgs("stock", "stock", sqlmock.AnyArg(), sqlmock.AnyArg(), 4, 3, 1).WillReturnResult(driver.RowsAffected(0))
+	suite.mock.ExpectCommit()
+
+	_, err := suite.repo.Upsert(context.Background(), tag.Tag{ID: 1, Tag: "stock", TagKey: "stock", Version: 3})
+	suite.ErrorIs(err, etag.ErrPreconditionFailed)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TagRepoTestSuite) TestAddExistingTagSuite() {
+	const sql = `SELECT * FROM "tags" WHERE tag_key = $1 OR id IN (SELECT tag_id FROM tag_aliases WHERE alias_key = $2) ORDER BY "tags"."id" LIMIT 1`
+
+	suite.mock.
+		ExpectQuery(sql).
+		WithArgs("stock market", "stock market").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tag", "tag_key", "version"}).AddRow(4, "Stock Market", "stock market", 2))
+
+	usecase := tag.NewUseCase(suite.repo)
+	existing, err := usecase.Add(context.Background(), tag.Tag{Tag: "  STOCK   market "})
+	suite.ErrorIs(err, tag.ErrExists)
+	suite.Equal(uint(4), existing.ID)
+	suite.Equal("Stock Market", existing.Tag)
+
+	_, err = usecase.Add(context.Background(), tag.Tag{Tag: "   "})
+	suite.Error(err)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TagRepoTestSuite) TestFindOrCreateTagSuite() {
+	const nameSQL = `SELECT * FROM "tags" WHERE tag_key = $1 OR id IN (SELECT tag_id FROM tag_aliases WHERE alias_key = $2) ORDER BY "tags"."id" LIMIT 1`
+	const insertSQL = `INSERT INTO "tags" ("tag","version","tag_key") VALUES ($1,$2,$3) RETURNING "tag_key","created_at","updated_at","id"`
+
+	suite.mock.ExpectQuery(nameSQL).WithArgs("crypto", "crypto").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	suite.mock.ExpectBegin()
+	suite.mock.
+		ExpectQuery(insertSQL).WithArgs("Crypto", 1, "crypto").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(9, time.Now(), time.Now()))
+	suite.mock.ExpectCommit()
+	suite.mock.
+		ExpectQuery(nameSQL).
+		WithArgs("crypto", "crypto").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tag", "tag_key", "version"}).AddRow(9, "Crypto", "crypto", 1))
+
+	usecase := tag.NewUseCase(suite.repo)
+	res, created, err := usecase.FindOrCreate(context.Background(), " Crypto ")
+	suite.Empty(err)
+	suite.True(created)
+	suite.Equal(uint(9), res.ID)
+
+	res, created, err = usecase.FindOrCreate(context.Background(), "CRYPTO")
+	suite.Empty(err)
+	suite.False(created)
+	suite.Equal(uint(9), res.ID)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TagRepoTestSuite) TestAddConcurrentTagSuite() {
+	const nameSQL = `SELECT * FROM "tags" WHERE tag_key = $1 OR id IN (SELECT tag_id FROM tag_aliases WHERE alias_key = $2) ORDER BY "tags"."id" LIMIT 1`
+	const insertSQL = `INSERT INTO "tags" ("tag","version","tag_key") VALUES ($1,$2,$3) RETURNING "tag_key","created_at","updated_at","id"`
+
+	// the tag is created by another request between the check and the insert
+	suite.mock.ExpectQuery(nameSQL).WithArgs("crypto", "crypto").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	suite.mock.ExpectBegin()
+	suite.mock.
+		ExpectQuery(insertSQL).WithArgs("Crypto", 1, "crypto").
+		WillReturnError(&pgconn.PgError{Code: "23505", ConstraintName: "idx_tags_tag_key"})
+	suite.mock.ExpectRollback()
+	suite.mock.
+		ExpectQuery(nameSQL).
+		WithArgs("crypto", "crypto").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tag", "tag_key", "version"}).AddRow(9, "Crypto", "crypto", 1))
+
+	usecase := tag.NewUseCase(suite.repo)
+	existing, err := usecase.Add(context.Background(), tag.Tag{Tag: "Crypto"})
+	suite.ErrorIs(err, tag.ErrExists)
+	suite.Equal(uint(9), existing.ID)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TagRepoTestSuite) TestFirstOrCreateConcurrentTagSuite() {
+	const nameSQL = `SELECT * FROM "tags" WHERE tag_key = $1 OR id IN (SELECT tag_id FROM tag_aliases WHERE alias_key = $2) ORDER BY "tags"."id" LIMIT 1`
+	const insertSQL = `INSERT INTO "tags" ("tag","version","tag_key") VALUES ($1,$2,$3) RETURNING "tag_key","created_at","updated_at","id"`
+
+	suite.mock.ExpectQuery(nameSQL).WithArgs("crypto", "crypto").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	suite.mock.ExpectBegin()
+	suite.mock.
+		ExpectQuery(insertSQL).WithArgs("Crypto", 1, "crypto").
+		WillReturnError(&pgconn.PgError{Code: "23505", ConstraintName: "idx_tags_tag_key"})
+	suite.mock.ExpectRollback()
+	suite.mock.
+		ExpectQuery(nameSQL).
+		WithArgs("crypto", "crypto").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tag", "tag_key", "version"}).AddRow(9, "Crypto", "crypto", 1))
+
+	res, err := suite.repo.FirstOrCreate(context.Background(), " Crypto ")
+	suite.Empty(err)
+	suite.Equal(uint(9), res.ID)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TagRepoTestSuite) TestMergeTagSuite() {
+	const selectSQL = `SELECT * FROM "tags" WHERE "tags"."id" = $1 ORDER BY "tags"."id" LIMIT 1`
+	const newsSQL = `SELECT DISTINCT news_id FROM "news_tags" WHERE tag_id in ($1,$2)`
+	const moveSQL = `INSERT INTO news_tags (news_id, tag_id) SELECT DISTINCT news_id, $1 FROM news_tags WHERE tag_id IN ($2,$3) ON CONFLICT DO NOTHING`
+	const unlinkSQL = `DELETE FROM news_tags WHERE tag_id IN ($1,$2)`
+	const versionSQL = `UPDATE news SET version = version + 1 WHERE id IN ($1,$2,$3)`
+	const moveAliasSQL = `UPDATE tag_aliases SET tag_id = $1 WHERE tag_id IN ($2,$3)`
+	const aliasSQL = `INSERT INTO tag_aliases (alias, alias_key, tag_id) SELECT tag, tag_key, $1 FROM tags WHERE id IN ($2,$3) ON CONFLICT DO NOTHING`
+	const deleteSQL = `DELETE FROM "tags" WHERE id in ($1,$2)`
+
+	for _, id := range []int{1, 2, 3} {
+		suite.mock.
+			ExpectQuery(selectSQL).
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "tag", "tag_key", "version"}).AddRow(id, "stock", "stock", 1))
+	}
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery(newsSQL).WithArgs(2, 3).WillReturnRows(sqlmock.NewRows([]string{"news_id"}).AddRow(10).AddRow(11).AddRow(12))
+	suite.mock.ExpectExec(moveSQL).WithArgs(1, 2, 3).WillReturnResult(driver.RowsAffected(2))
+	suite.mock.ExpectExec(unlinkSQL).WithArgs(2, 3).WillReturnResult(driver.RowsAffected(4))
+	suite.mock.ExpectExec(versionSQL).WithArgs(10, 11, 12).WillReturnResult(driver.RowsAffected(3))
+	suite.mock.ExpectExec(moveAliasSQL).WithArgs(1, 2, 3).WillReturnResult(driver.RowsAffected(1))
+	suite.mock.ExpectExec(aliasSQL).WithArgs(1, 2, 3).WillReturnResult(driver.RowsAffected(2))
+	suite.mock.ExpectExec(deleteSQL).WithArgs(2, 3).WillReturnResult(driver.RowsAffected(2))
+	suite.mock.ExpectCommit()
+
+	usecase := tag.NewUseCase(suite.repo)
+	res, err := usecase.Merge(context.Background(), 1, tag.MergeDTO{Sources: []uint{2, 3, 2}})
+	suite.Empty(err)
+	suite.Equal(uint(1), res.Tag.ID)
+	suite.Equal([]uint{2, 3}, res.Merged)
+	suite.Equal(int64(3), res.News)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TagRepoTestSuite) TestMergeTagItselfSuite() {
+	const selectSQL = `SELECT * FROM "tags" WHERE "tags"."id" = $1 ORDER BY "tags"."id" LIMIT 1`
+
+	suite.mock.
+		ExpectQuery(selectSQL).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tag", "tag_key", "version"}).AddRow(1, "stock", "stock", 1))
+
+	usecase := tag.NewUseCase(suite.repo)
+	_, err := usecase.Merge(context.Background(), 1, tag.MergeDTO{Sources: []uint{1}})
+	suite.ErrorIs(err, tag.ErrMergeItself)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TagRepoTestSuite) TestAddAliasTagSuite() {
+	const selectSQL = `SELECT * FROM "tags" WHERE "tags"."id" = $1 ORDER BY "tags"."id" LIMIT 1`
+	const nameSQL = `SELECT * FROM "tags" WHERE tag_key = $1 OR id IN (SELECT tag_id FROM tag_aliases WHERE alias_key = $2) ORDER BY "tags"."id" LIMIT 1`
+	const insertSQL = `INSERT INTO "tag_aliases" ("alias","alias_key","tag_id") VALUES ($1,$2,$3) RETURNING "created_at","id"`
+	bitcoin := sqlmock.NewRows([]string{"id", "tag", "tag_key", "version"}).AddRow(1, "Bitcoin", "bitcoin", 1)
+
+	suite.mock.ExpectQuery(selectSQL).WithArgs(1).WillReturnRows(bitcoin)
+	suite.mock.ExpectQuery(nameSQL).WithArgs("btc", "btc").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	suite.mock.ExpectBegin()
+	suite.mock.
+		ExpectQuery(insertSQL).WithArgs("BTC", "btc", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(5, time.Now()))
+	suite.mock.ExpectCommit()
+
+	usecase := tag.NewUseCase(suite.repo)
+	alias, err := usecase.AddAlias(context.Background(), 1, " BTC ")
+	suite.Empty(err)
+	suite.Equal(uint(5), alias.ID)
+	suite.Equal(uint(1), alias.TagID)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TagRepoTestSuite) TestAddAliasConflictTagSuite() {
+	const selectSQL = `SELECT * FROM "tags" WHERE "tags"."id" = $1 ORDER BY "tags"."id" LIMIT 1`
+	const nameSQL = `SELECT * FROM "tags" WHERE tag_key = $1 OR id IN (SELECT tag_id FROM tag_aliases WHERE alias_key = $2) ORDER BY "tags"."id" LIMIT 1`
+	columns := []string{"id", "tag", "tag_key", "version"}
+
+	// the name of another tag
+	suite.mock.ExpectQuery(selectSQL).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "Bitcoin", "bitcoin", 1))
+	suite.mock.ExpectQuery(nameSQL).WithArgs("crypto", "crypto").WillReturnRows(sqlmock.NewRows(columns).AddRow(2, "Crypto", "crypto", 1))
+
+	// the name of the tag itself
+	suite.mock.ExpectQuery(selectSQL).WithArgs(1).WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "Bitcoin", "bitcoin", 1))
+
+	usecase := tag.NewUseCase(suite.repo)
+	alias, err := usecase.AddAlias(context.Background(), 1, "Crypto")
+	suite.ErrorIs(err, tag.ErrExists)
+	suite.Equal(uint(2), alias.TagID)
+	suite.Equal("Crypto", alias.Tag.Tag)
+
+	_, err = usecase.AddAlias(context.Background(), 1, "BITCOIN")
+	suite.ErrorIs(err, tag.ErrAliasCycle)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TagRepoTestSuite) TestSuggestTagSuite() {
+	const sql = `SELECT tags.id, tags.tag, CASE WHEN tags.tag_key LIKE $1 THEN NULL ELSE ` +
+		`(SELECT alias FROM tag_aliases WHERE tag_aliases.tag_id = tags.id AND alias_key LIKE $2 ORDER BY alias_key LIMIT 1) END AS alias, ` +
+		`count(news.id) AS uses, max(coalesce(news.publish_at, news.created_at)) AS last_used_at, tags.tag_key = $3 AS exact, ` +
+		`coalesce(sum(power(0.5, extract(epoch from now() - coalesce(news.publish_at, news.created_at)) / 86400 / $4)), 0) AS score ` +
+		`FROM "tags" LEFT JOIN news_tags ON news_tags.tag_id = tags.id ` +
+		`LEFT JOIN news ON news.id = news_tags.news_id AND news.status = 'publish' AND news.deleted_at IS NULL ` +
+		`WHERE tags.tag_key LIKE $5 OR tags.id IN (SELECT tag_id FROM tag_aliases WHERE alias_key LIKE $6) ` +
+		`GROUP BY "tags"."id" ORDER BY exact DESC, score DESC, tags.tag_key LIMIT 10`
+	lastUsed := time.Now()
+
+	// the wildcards typed are matched literally
+	suite.mock.
+		ExpectQuery(sql).
+		WithArgs(`in\_v%`, `in\_v%`, "in_v", 30, `in\_v%`, `in\_v%`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tag", "alias", "uses", "last_used_at", "exact", "score"}).
+			AddRow(3, "In_vest", nil, 12, lastUsed, false, 4.2).
+			AddRow(8, "Mutual fund", "in_vesting", 0, nil, false, 0))
+
+	usecase := tag.NewUseCase(suite.repo)
+	res, err := usecase.Suggest(context.Background(), " IN_V", 10)
+	suite.Empty(err)
+	suite.Len(res, 2)
+	suite.Equal(uint(3), res[0].ID)
+	suite.Equal(int64(12), res[0].Uses)
+	suite.NotNil(res[0].LastUsedAt)
+	suite.Equal("in_vesting", res[1].Alias)
+	suite.Nil(res[1].LastUsedAt)
+
+	_, err = usecase.Suggest(context.Background(), "  ", 10)
+	suite.Error(err)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TagRepoTestSuite) TestStatsTagSuite() {
+	const sql = `SELECT tags.id, tags.tag, count(news.id) AS published, ` +
+		`count(news.id) FILTER (WHERE news.publish_at >= $1) AS published_7d, ` +
+		`count(news.id) FILTER (WHERE news.publish_at >= $2) AS published_30d, ` +
+		`count(news.id) FILTER (WHERE news.publish_at >= $3) AS published_365d, ` +
+		`min(news.publish_at) AS first_used_at, max(news.publish_at) AS last_used_at ` +
+		`FROM "tags" LEFT JOIN news_tags ON news_tags.tag_id = tags.id ` +
+		`LEFT JOIN news ON news.id = news_tags.news_id AND news.status = 'publish' AND news.deleted_at IS NULL ` +
+		`GROUP BY "tags"."id" ORDER BY "published" DESC,tags.id LIMIT 20 OFFSET 20`
+	const countSQL = `SELECT count(*) FROM "tags"`
+	firstUsed, lastUsed := time.Now().AddDate(-1, 0, 0), time.Now()
+
+	suite.mock.
+		ExpectQuery(sql).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tag", "published", "published_7d", "published_30d", "published_365d", "first_used_at", "last_used_at"}).
+			AddRow(1, "stock", 40, 2, 9, 30, firstUsed, lastUsed).
+			AddRow(2, "ipo", 0, 0, 0, 0, nil, nil))
+	suite.mock.ExpectQuery(countSQL).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(22))
+
+	filter := tag.StatsFilter{Sort: "-published", Pagination: pagination.Pagination{Page: 2, Limit: 20}}
+	ctx := context.WithValue(context.Background(), tag.ContextKey("tag_stats_filter"), filter)
+
+	usecase := tag.NewUseCase(suite.repo)
+	stats, total, err := usecase.Stats(ctx)
+	suite.Empty(err)
+	suite.Equal(int64(22), total)
+	suite.Len(stats, 2)
+	suite.Equal(int64(40), stats[0].Published)
+	suite.Equal(int64(2), stats[0].Published7d)
+	suite.Equal(int64(9), stats[0].Published30d)
+	suite.Equal(int64(30), stats[0].Published1y)
+	suite.NotNil(stats[0].FirstUsedAt)
+	suite.Nil(stats[1].LastUsedAt)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TagRepoTestSuite) TestCloudTagSuite() {
+	const sql = `SELECT tags.id, tags.tag, count(news.id) AS published FROM "tags" ` +
+		`JOIN news_tags ON news_tags.tag_id = tags.id JOIN news ON news.id = news_tags.news_id ` +
+		`WHERE (news.status = $1 AND news.deleted_at IS NULL) AND news.publish_at >= $2 ` +
+		`GROUP BY "tags"."id" ORDER BY published DESC, tags.tag_key LIMIT 50`
+
+	suite.mock.
+		ExpectQuery(sql).
+		WithArgs("publish", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tag", "published"}).
+			AddRow(1, "Stock", 100).
+			AddRow(2, "bitcoin", 10).
+			AddRow(3, "IPO", 1))
+
+	usecase := tag.NewUseCase(suite.repo)
+	cloud, err := usecase.Cloud(context.Background(), 50, 5, 30)
+	suite.Empty(err)
+	suite.Len(cloud, 3)
+
+	// sorted by name, weighted on a log scale
+	suite.Equal("bitcoin", cloud[0].Tag)
+	suite.Equal(3, cloud[0].Weight)
+	suite.Equal("IPO", cloud[1].Tag)
+	suite.Equal(1, cloud[1].Weight)
+	suite.Equal("Stock", cloud[2].Tag)
+	suite.Equal(5, cloud[2].Weight)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TagRepoTestSuite) TestParseIfMatchSuite() {
+	version, err := etag.Parse(etag.Format(7))
+	suite.Empty(err)
+	suite.Equal(uint(7), version)
+
+	version, err = etag.Parse(`W/"7"`)
+	suite.Empty(err)
+	suite.Equal(uint(7), version)
+
+	version, err = etag.Parse("*")
+	suite.Empty(err)
+	suite.Equal(uint(0), version)
+
+	_, err = etag.Parse("")
+	suite.ErrorIs(err, etag.ErrPreconditionRequired)
+
+	_, err = etag.Parse("7")
+	suite.Error(err)
+}
+
+func (suite *TagRepoTestSuite) TestApplyPatchSuite() {
+	doc := []byte(`{"id":1,"tag":"fund","created_at":"2022-06-20T00:00:00Z"}`)
+
+	res, err := patch.Apply("application/merge-patch+json; charset=utf-8", doc, []byte(`{"tag":"stock","created_at":null}`))
+	suite.Empty(err)
+	suite.JSONEq(`{"id":1,"tag":"stock"}`, string(res))
+
+	_, err = patch.Apply(patch.JSONPatch, doc, []byte(`[{"op": "test", "path": "/tag", "value": "stock"}]`))
+	suite.ErrorIs(err, patch.ErrInvalidPatch)
+
+	_, err = patch.Apply("text/plain", doc, []byte(`tag=stock`))
+	suite.ErrorIs(err, patch.ErrUnsupportedMediaType)
+}
+
 func TestTagRepoTestSuite(t *testing.T) {
 	suite.Run(t, new(TagRepoTestSuite))
 }
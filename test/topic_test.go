@@ -9,6 +9,8 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/ntm/internal/domain/topic"
+	"github.com/ntm/internal/pkg/common/pagination"
+	"github.com/ntm/internal/pkg/common/sorting"
 	"github.com/stretchr/testify/suite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -37,11 +39,11 @@ func (suite *TopicRepoTestSuite) SetupTest() {
 func (suite *TopicRepoTestSuite) TestSaveTopicSuite() {
 	id := uint(1)
 	topic := topic.Topic{Topic: "investment", CreatedAt: time.Now()}
-	const sql = `INSERT INTO "topics" ("topic","created_at") VALUES ($1,$2) RETURNING "created_at","updated_at","id"`
+	const sql = `INSERT INTO "topics" ("topic","version","created_at") VALUES ($1,$2,$3) RETURNING "created_at","updated_at","id"`
 
 	suite.mock.ExpectBegin()
 	suite.mock.
-		ExpectQuery(sql).WithArgs(topic.Topic, topic.CreatedAt).
+		ExpectQuery(sql).WithArgs(topic.Topic, 1, topic.CreatedAt).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(id, time.Now()))
 	suite.mock.ExpectCommit()
 
@@ -151,6 +153,37 @@ func (suite *TopicRepoTestSuite) TestGetAllTopicSuite() {
 	}
 }
 
+func (suite *TopicRepoTestSuite) TestGetAllTopicSortedSuite() {
+	rows := sqlmock.
+		NewRows([]string{"id", "topic", "updated_at", "created_at"}).
+		AddRow(2, "investment fund", nil, time.Now()).AddRow(1, "fund", nil, time.Now())
+
+	const sqlQuery = `SELECT * FROM "topics" ORDER BY "created_at" DESC,"topic",id LIMIT 10`
+
+	suite.mock.
+		ExpectQuery(sqlQuery).
+		WillReturnRows(rows)
+
+	filter := topic.Filter{Sort: "-created_at,topic", Pagination: pagination.Pagination{Page: 1, Limit: 10}}
+	ctx := context.WithValue(context.Background(), topic.ContextKey("topics_filter"), filter)
+	topics, err := suite.repo.GetAll(ctx)
+	suite.Empty(err)
+	suite.Len(topics, 2)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TopicRepoTestSuite) TestParseTopicSortSuite() {
+	sort, err := sorting.Parse("-created_at, topic", topic.SortFields)
+	suite.Empty(err)
+	suite.Equal("-created_at,topic", sort.String())
+
+	_, err = sorting.Parse("content", topic.SortFields)
+	suite.EqualError(err, `unknown sort field "content", allowed fields are created_at, id, topic, updated_at`)
+}
+
 func TestTopicRepoTestSuite(t *testing.T) {
 	suite.Run(t, new(TopicRepoTestSuite))
 }
@@ -0,0 +1,126 @@
+package persistence
+
+import (
+	"context"
+	"database/sql/driver"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ntm/internal/domain/writer"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type WriterRepoTestSuite struct {
+	suite.Suite
+	mock sqlmock.Sqlmock
+	repo writer.Repository
+}
+
+func (suite *WriterRepoTestSuite) SetupTest() {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		log.Fatalf("an error '%v' was not expected when opening a stub database connection", err)
+	}
+
+	gdb, err1 := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	suite.Equal(nil, err1)
+
+	repo := writer.NewRepository(gdb)
+	suite.mock = mock
+	suite.repo = repo
+}
+
+func (suite *WriterRepoTestSuite) TestSaveWriterSuite() {
+	id := uint(1)
+	writer := writer.Writer{Name: "Setia Budi", NameKey: "setia budi", Bio: "markets desk", CreatedAt: time.Now()}
+	const sql = `INSERT INTO "writers" ("name","name_key","bio","avatar","created_at") VALUES ($1,$2,$3,$4,$5) RETURNING "created_at","updated_at","id"`
+
+	suite.mock.ExpectBegin()
+	suite.mock.
+		ExpectQuery(sql).WithArgs(writer.Name, writer.NameKey, writer.Bio, writer.Avatar, writer.CreatedAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(1, time.Now(), time.Now()))
+	suite.mock.ExpectCommit()
+
+	writer, err := suite.repo.Upsert(context.Background(), writer)
+	suite.Empty(err)
+	suite.Equal(id, writer.ID)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *WriterRepoTestSuite) TestGetWriterByNameSuite() {
+	rows := sqlmock.
+		NewRows([]string{"id", "name", "name_key", "updated_at", "created_at"}).
+		AddRow(1, "Setia Budi", "setia budi", nil, time.Now())
+
+	const sql = `SELECT * FROM "writers" WHERE name_key = $1 ORDER BY "writers"."id" LIMIT 1`
+
+	suite.mock.
+		ExpectQuery(sql).
+		WithArgs("setia budi").
+		WillReturnRows(rows)
+
+	writer, err := suite.repo.GetByName(context.Background(), "  SETIA   budi ")
+	suite.Empty(err)
+	suite.Equal("Setia Budi", writer.Name)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *WriterRepoTestSuite) TestCountWriterNewsSuite() {
+	const sql = `SELECT count(*) FROM "news" WHERE writer_id = $1`
+
+	suite.mock.
+		ExpectQuery(sql).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+
+	total, err := suite.repo.CountNews(context.Background(), 1)
+	suite.Empty(err)
+	suite.Equal(int64(4), total)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *WriterRepoTestSuite) TestDeleteWriterSuite() {
+	const sql = `DELETE FROM "writers" WHERE "writers"."id" = $1`
+	const id = 1
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec(sql).WithArgs(id).WillReturnResult(driver.RowsAffected(1))
+	suite.mock.ExpectCommit()
+
+	err := suite.repo.DeleteByID(context.Background(), id)
+	suite.Empty(err)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *WriterRepoTestSuite) TestRenameWriterNewsSuite() {
+	const sql = `UPDATE news SET writer = $1, version = version + 1 WHERE writer_id = $2 AND writer IS DISTINCT FROM $3`
+
+	suite.mock.ExpectExec(sql).WithArgs("Setia Budi", 1, "Setia Budi").WillReturnResult(driver.RowsAffected(3))
+
+	err := suite.repo.RenameNews(context.Background(), 1, "Setia Budi")
+	suite.Empty(err)
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestWriterRepoTestSuite(t *testing.T) {
+	suite.Run(t, new(WriterRepoTestSuite))
+}